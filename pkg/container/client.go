@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/distribution/reference"
 	godigest "github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -18,11 +19,14 @@ type Client struct {
 	ref         string
 	originalRef string
 	repo        *remote.Repository
+	policy      tagPolicy
 }
 
 // NewClient creates a new OCI registry client for the given reference.
 // The ref may include an oci:// prefix and/or an embedded tag; both are
-// stripped. Tags are passed separately to Pull.
+// stripped. Tags are passed separately to Pull. Registries on a loopback
+// address or "localhost" are tried over HTTPS first and fall back to plain
+// HTTP when they do not speak TLS, so WithPlainHTTP is not needed for them.
 func NewClient(ref string, opts ...ClientOption) (*Client, error) {
 	var options ClientOptions
 	for _, opt := range opts {
@@ -43,20 +47,44 @@ func NewClient(ref string, opts ...ClientOption) (*Client, error) {
 		return nil, fmt.Errorf("unable to create repository for %q: %w", ref, err)
 	}
 
+	policy := tagPolicy{prereleases: options.Prereleases}
+
+	if options.MinVersion != "" {
+		policy.minVersion, err = semver.NewVersion(options.MinVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minimum version %q: %w", options.MinVersion, err)
+		}
+	}
+
+	host := reference.Domain(named)
+
+	httpClient := newHTTPClient(&options)
+	if !options.PlainHTTP && isLoopback(host) {
+		httpClient = withPlainHTTPFallback(httpClient)
+	}
+
+	if len(options.Header) > 0 {
+		httpClient = withHostHeader(httpClient, host, options.Header)
+	}
+
 	repo.PlainHTTP = options.PlainHTTP
-	repo.Client = &auth.Client{Credential: computeCredentials(named, &options)}
+	repo.Client = &auth.Client{
+		Client:     httpClient,
+		Credential: computeCredentials(named, &options),
+	}
 
-	return &Client{ref: repoRef, originalRef: ref, repo: repo}, nil
+	return &Client{ref: repoRef, originalRef: ref, repo: repo, policy: policy}, nil
 }
 
-// Pull fetches the Helm chart .tgz bytes for the given tag.
+// Pull fetches the Helm chart .tgz bytes for the given tag. Build metadata
+// in a semver tag ("1.0.0+build.1") is mapped to the "_" form registries store.
 // Only the chart layer is downloaded; config and provenance layers are skipped.
 func (c *Client) Pull(ctx context.Context, tag string) ([]byte, error) {
 	if tag == "" {
 		return nil, fmt.Errorf("%w: %q", ErrEmptyTag, c.ref)
 	}
 
-	fullRef := c.ref + ":" + tag
+	fullRef := c.ref + ":" + ociTag(tag)
 
 	return c.fetchChart(ctx, fullRef)
 }
@@ -79,36 +107,90 @@ func (c *Client) PullDigest(ctx context.Context, dgst godigest.Digest) ([]byte,
 func (c *Client) Tags(ctx context.Context) ([]string, error) {
 	tags, err := listTags(ctx, c.repo)
 	if err != nil {
-		return nil, fmt.Errorf("unable to list tags for %q: %w", c.ref, err)
+		return nil, c.wrapErr(fmt.Errorf("unable to list tags for %q: %w", c.ref, err))
 	}
 
 	return tags, nil
 }
 
 // ResolveTag determines the tag to pull for this client's reference.
-// It checks for embedded tags, explicit version overrides, and falls back
-// to listing tags from the registry to pick the latest semver.
+// Embedded tags and literal versions are returned as is without contacting
+// the registry. A semver constraint such as "~1.4" or ">=2.0.0 <3" lists the
+// registry tags and picks the highest match, and an empty version picks the
+// latest semver tag. Prerelease tags are only considered with WithPrereleases,
+// and WithMinVersion excludes lower tags and rejects lower literal versions.
 func (c *Client) ResolveTag(ctx context.Context, version string) (string, error) {
-	return resolveTag(ctx, c.originalRef, version, c.Tags)
+	return resolveTag(ctx, c.originalRef, version, c.policy, c.Tags)
+}
+
+// Reference returns the "oci://registry/repo:tag" reference for tag, with
+// build metadata mapped to the OCI tag form.
+func (c *Client) Reference(tag string) string {
+	return "oci://" + c.ref + ":" + ociTag(tag)
+}
+
+// Layers resolves the given tag to the descriptors of its chart and
+// provenance layers without downloading them. The chart layer digest
+// identifies the chart archive and can be used to look up a cached copy
+// before calling FetchLayer.
+func (c *Client) Layers(ctx context.Context, tag string) (ChartLayers, error) {
+	if tag == "" {
+		return ChartLayers{}, fmt.Errorf("%w: %q", ErrEmptyTag, c.ref)
+	}
+
+	return c.chartLayers(ctx, c.ref+":"+ociTag(tag))
+}
+
+// LayersDigest resolves the given manifest digest to the descriptors of its
+// chart and provenance layers without downloading them.
+func (c *Client) LayersDigest(ctx context.Context, dgst godigest.Digest) (ChartLayers, error) {
+	if err := dgst.Validate(); err != nil {
+		return ChartLayers{}, fmt.Errorf("%w: %w", ErrInvalidDigest, err)
+	}
+
+	return c.chartLayers(ctx, c.ref+"@"+dgst.String())
+}
+
+// FetchLayer downloads the blob described by desc, typically a descriptor
+// returned by Layers or LayersDigest.
+func (c *Client) FetchLayer(ctx context.Context, desc ocispec.Descriptor) ([]byte, error) {
+	data, err := fetchBlob(ctx, c.repo, desc)
+	if err != nil {
+		return nil, c.wrapErr(fmt.Errorf("unable to fetch layer %s from %q: %w", desc.Digest, c.ref, err))
+	}
+
+	return data, nil
 }
 
 func (c *Client) fetchChart(ctx context.Context, fullRef string) ([]byte, error) {
-	resolved, err := c.resolveManifest(ctx, fullRef)
+	layers, err := c.chartLayers(ctx, fullRef)
 	if err != nil {
 		return nil, err
 	}
 
-	chartLayer := findChartLayer(resolved.manifest)
-	if chartLayer == nil {
-		return nil, fmt.Errorf("%w: %q", ErrNoChartLayer, fullRef)
+	data, err := fetchBlob(ctx, c.repo, layers.Chart)
+	if err != nil {
+		return nil, c.wrapErr(fmt.Errorf("unable to fetch chart layer for %q: %w", fullRef, err))
 	}
 
-	data, err := fetchBlob(ctx, c.repo, *chartLayer)
+	return data, nil
+}
+
+func (c *Client) chartLayers(ctx context.Context, fullRef string) (ChartLayers, error) {
+	resolved, err := c.resolveManifest(ctx, fullRef)
 	if err != nil {
-		return nil, fmt.Errorf("unable to fetch chart layer for %q: %w", fullRef, err)
+		return ChartLayers{}, err
 	}
 
-	return data, nil
+	chartLayer := findChartLayer(resolved.manifest)
+	if chartLayer == nil {
+		return ChartLayers{}, fmt.Errorf("%w: %q", ErrNoChartLayer, fullRef)
+	}
+
+	return ChartLayers{
+		Chart:      *chartLayer,
+		Provenance: findProvenanceLayer(resolved.manifest),
+	}, nil
 }
 
 // resolveManifest resolves the reference and fetches its manifest.
@@ -117,7 +199,7 @@ func (c *Client) fetchChart(ctx context.Context, fullRef string) ([]byte, error)
 func (c *Client) resolveManifest(ctx context.Context, fullRef string) (*resolvedArtifact, error) {
 	desc, err := c.repo.Resolve(ctx, fullRef)
 	if err != nil {
-		return nil, fmt.Errorf("unable to resolve %q: %w", fullRef, err)
+		return nil, c.wrapErr(fmt.Errorf("unable to resolve %q: %w", fullRef, err))
 	}
 
 	if !isIndexType(desc.MediaType) {
@@ -162,3 +244,8 @@ func (c *Client) resolveManifest(ctx context.Context, fullRef string) (*resolved
 
 	return nil, fmt.Errorf("%w: %q", ErrNoChartLayer, fullRef)
 }
+
+// wrapErr marks errors caused by a rejected certificate as *CertificateError.
+func (c *Client) wrapErr(err error) error {
+	return WrapCertificateError(c.repo.Reference.Registry, err)
+}
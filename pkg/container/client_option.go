@@ -1,6 +1,9 @@
 package container
 
 import (
+	"crypto/tls"
+	"net/http"
+
 	"oras.land/oras-go/v2/registry/remote/auth"
 
 	"github.com/k8s-manifest-kit/pkg/util"
@@ -13,6 +16,31 @@ type ClientOption = util.Option[ClientOptions]
 type ClientOptions struct {
 	credential *auth.Credential
 	PlainHTTP  bool
+
+	// Header is added to every request sent to the registry host. It is not
+	// sent to token services or blob storage on other hosts.
+	Header http.Header
+
+	// TLSConfig configures TLS for registry connections, e.g. a custom CA
+	// pool or a client certificate. Nil uses the system defaults.
+	TLSConfig *tls.Config
+
+	// HTTPClient, when set, is used for every registry request as is. It
+	// replaces TLSConfig and the default retry behavior; registry
+	// authentication is still layered on top.
+	HTTPClient *http.Client
+
+	// Transport, when set and HTTPClient is nil, is used as the transport of
+	// the registry HTTP client. Like HTTPClient it replaces TLSConfig and the
+	// default retry behavior.
+	Transport http.RoundTripper
+
+	// Prereleases includes prerelease tags when ResolveTag picks the latest
+	// tag or the highest match for a constraint.
+	Prereleases bool
+
+	// MinVersion makes ResolveTag reject semver tags lower than this version.
+	MinVersion string
 }
 
 // ApplyTo applies the client options to the target configuration.
@@ -24,15 +52,65 @@ func (opts ClientOptions) ApplyTo(target *ClientOptions) {
 	if opts.PlainHTTP {
 		target.PlainHTTP = opts.PlainHTTP
 	}
+
+	if opts.Header != nil {
+		target.Header = opts.Header
+	}
+
+	if opts.TLSConfig != nil {
+		target.TLSConfig = opts.TLSConfig
+	}
+
+	if opts.HTTPClient != nil {
+		target.HTTPClient = opts.HTTPClient
+	}
+
+	if opts.Transport != nil {
+		target.Transport = opts.Transport
+	}
+
+	if opts.Prereleases {
+		target.Prereleases = opts.Prereleases
+	}
+
+	if opts.MinVersion != "" {
+		target.MinVersion = opts.MinVersion
+	}
 }
 
 // WithCredential sets the authentication credentials for the OCI client.
 func WithCredential(username string, password string) ClientOption {
 	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
-		opts.credential = &auth.Credential{
-			Username: username,
-			Password: password,
+		cred := opts.ensureCredential()
+		cred.Username = username
+		cred.Password = password
+	})
+}
+
+// WithAccessToken sets a bearer access token for the OCI client. The token is
+// used as is and never refreshed.
+func WithAccessToken(token string) ClientOption {
+	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
+		opts.ensureCredential().AccessToken = token
+	})
+}
+
+// WithRefreshToken sets an identity token for the OCI client, exchanged for
+// access tokens at the registry's token service.
+func WithRefreshToken(token string) ClientOption {
+	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
+		opts.ensureCredential().RefreshToken = token
+	})
+}
+
+// WithHeader adds a header to every request sent to the registry host.
+func WithHeader(key string, value string) ClientOption {
+	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
+		if opts.Header == nil {
+			opts.Header = http.Header{}
 		}
+
+		opts.Header.Set(key, value)
 	})
 }
 
@@ -42,3 +120,46 @@ func WithPlainHTTP(plain bool) ClientOption {
 		opts.PlainHTTP = plain
 	})
 }
+
+// WithTLSConfig sets the TLS configuration used for registry connections.
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
+		opts.TLSConfig = cfg
+	})
+}
+
+// WithHTTPClient sets the HTTP client used for registry requests.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
+		opts.HTTPClient = client
+	})
+}
+
+// WithTransport sets the transport of the HTTP client used for registry requests.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
+		opts.Transport = transport
+	})
+}
+
+// WithPrereleases includes prerelease tags when resolving versions.
+func WithPrereleases(include bool) ClientOption {
+	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
+		opts.Prereleases = include
+	})
+}
+
+// WithMinVersion rejects semver tags lower than version when resolving versions.
+func WithMinVersion(version string) ClientOption {
+	return util.FunctionalOption[ClientOptions](func(opts *ClientOptions) {
+		opts.MinVersion = version
+	})
+}
+
+func (opts *ClientOptions) ensureCredential() *auth.Credential {
+	if opts.credential == nil {
+		opts.credential = &auth.Credential{}
+	}
+
+	return opts.credential
+}
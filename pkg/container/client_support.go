@@ -2,11 +2,18 @@ package container
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/distribution/reference"
@@ -15,16 +22,34 @@ import (
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
 	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
 )
 
 const (
 	chartLayerMediaType         = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
 	legacyChartLayerMediaType   = "application/tar+gzip"
+	provenanceLayerMediaType    = "application/vnd.cncf.helm.chart.provenance.v1.prov"
 	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
 
 	maxBlobSize = 256 << 20 // 256 MiB hard upper bound for blob downloads
 )
 
+// certificateAlerts are the TLS alerts a server sends when it rejects the
+// client certificate (RFC 8446, section 6.2). Over TCP crypto/tls reports
+// them as a "remote error" *net.OpError wrapping an unexported alert type
+// whose message matches the tls.AlertError message.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var certificateAlerts = []tls.AlertError{
+	42,  // bad_certificate
+	43,  // unsupported_certificate
+	44,  // certificate_revoked
+	45,  // certificate_expired
+	46,  // certificate_unknown
+	48,  // unknown_ca
+	116, // certificate_required
+}
+
 // computeCredentials returns a credential function for OCI registry auth.
 // When no explicit credential is provided, it attempts to load the Docker
 // credential store. Initialization failures are intentionally swallowed
@@ -52,6 +77,167 @@ func computeCredentials(named reference.Named, opts *ClientOptions) auth.Credent
 	return credentials.Credential(store)
 }
 
+// newHTTPClient returns the HTTP client used for registry calls. A client or
+// transport from the options is used as is. Otherwise, without a TLS
+// configuration it is the oras default client; with one the default
+// transport is cloned with it, keeping the oras retry behavior.
+func newHTTPClient(opts *ClientOptions) *http.Client {
+	switch {
+	case opts.HTTPClient != nil:
+		return opts.HTTPClient
+	case opts.Transport != nil:
+		return &http.Client{Transport: opts.Transport}
+	case opts.TLSConfig == nil:
+		return retry.DefaultClient
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		transport = &http.Transport{}
+	}
+
+	transport = transport.Clone()
+	transport.TLSClientConfig = opts.TLSConfig
+
+	return &http.Client{Transport: retry.NewTransport(transport)}
+}
+
+// plainHTTPFallback retries requests to a local registry over plain HTTP when
+// the server answers HTTPS with an HTTP response. Once it fell back, all later
+// requests go straight to HTTP.
+type plainHTTPFallback struct {
+	base  http.RoundTripper
+	plain atomic.Bool
+}
+
+// withPlainHTTPFallback returns a copy of client whose transport falls back
+// to plain HTTP.
+func withPlainHTTPFallback(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	fallback := *client
+	fallback.Transport = &plainHTTPFallback{base: base}
+
+	return &fallback
+}
+
+func (t *plainHTTPFallback) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.base.RoundTrip(req)
+	}
+
+	if !t.plain.Load() {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil || !isSchemeMismatch(err) {
+			return resp, err //nolint:wrapcheck // transparent transport
+		}
+
+		t.plain.Store(true)
+	}
+
+	plain := req.Clone(req.Context())
+	plain.URL.Scheme = "http"
+
+	return t.base.RoundTrip(plain) //nolint:wrapcheck // transparent transport
+}
+
+// hostHeader adds header to requests for host only, so that custom headers
+// do not reach token services or blob redirects on other hosts.
+type hostHeader struct {
+	base   http.RoundTripper
+	host   string
+	header http.Header
+}
+
+// withHostHeader returns a copy of client that adds header to requests for host.
+func withHostHeader(client *http.Client, host string, header http.Header) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	scoped := *client
+	scoped.Transport = &hostHeader{base: base, host: host, header: header}
+
+	return &scoped
+}
+
+func (t *hostHeader) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == t.host {
+		req = req.Clone(req.Context())
+		for k, v := range t.header {
+			req.Header[k] = v
+		}
+	}
+
+	return t.base.RoundTrip(req) //nolint:wrapcheck // transparent transport
+}
+
+// isSchemeMismatch reports whether err was caused by a server answering a
+// TLS handshake with a plain HTTP response.
+func isSchemeMismatch(err error) bool {
+	var recordErr tls.RecordHeaderError
+
+	return errors.Is(err, http.ErrSchemeMismatch) ||
+		(errors.As(err, &recordErr) && string(recordErr.RecordHeader[:]) == "HTTP/")
+}
+
+// isLoopback reports whether host, optionally with a port, is "localhost" or
+// a loopback address.
+func isLoopback(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// WrapCertificateError returns err wrapped in a *CertificateError for host
+// when it was caused by a rejected certificate, and err unchanged otherwise.
+func WrapCertificateError(host string, err error) error {
+	if err == nil || IsCertificateError(err) || !isCertificateFailure(err) {
+		return err
+	}
+
+	return &CertificateError{Host: host, Err: err}
+}
+
+func isCertificateFailure(err error) bool {
+	var (
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		alert        tls.AlertError
+		opErr        *net.OpError
+	)
+
+	switch {
+	case errors.As(err, &verifyErr),
+		errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr):
+		return true
+	case errors.As(err, &alert):
+		return slices.Contains(certificateAlerts, alert)
+	case errors.As(err, &opErr) && opErr.Op == "remote error" && opErr.Err != nil:
+		return slices.ContainsFunc(certificateAlerts, func(a tls.AlertError) bool {
+			return a.Error() == opErr.Err.Error()
+		})
+	default:
+		return false
+	}
+}
+
 func listTags(ctx context.Context, repo *remote.Repository) ([]string, error) {
 	var tags []string
 
@@ -98,9 +284,26 @@ func fetchBlob(ctx context.Context, repo *remote.Repository, desc ocispec.Descri
 		return nil, fmt.Errorf("read %s: %w", desc.Digest, err)
 	}
 
+	if err := verifyDigest(desc.Digest, data); err != nil {
+		return nil, err
+	}
+
 	return data, nil
 }
 
+// verifyDigest checks data against expected using the digest's own algorithm.
+func verifyDigest(expected godigest.Digest, data []byte) error {
+	if err := expected.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDigest, err)
+	}
+
+	if actual := expected.Algorithm().FromBytes(data); actual != expected {
+		return &DigestMismatchError{Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
 func fetch[T any](ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor) (T, error) {
 	var zero T
 
@@ -141,6 +344,18 @@ func findChartLayer(manifest ocispec.Manifest) *ocispec.Descriptor {
 	return layer
 }
 
+// findProvenanceLayer returns the provenance layer Helm attaches to signed
+// charts, or nil if the manifest has none.
+func findProvenanceLayer(manifest ocispec.Manifest) *ocispec.Descriptor {
+	for i := range manifest.Layers {
+		if manifest.Layers[i].MediaType == provenanceLayerMediaType {
+			return &manifest.Layers[i]
+		}
+	}
+
+	return nil
+}
+
 // resolveTag determines the tag to pull. Digest refs are never passed here --
 // the caller (locator_oci.pull) checks for an embedded digest first and routes
 // to PullDigest, bypassing tag resolution entirely.
@@ -148,6 +363,7 @@ func resolveTag(
 	ctx context.Context,
 	ref string,
 	version string,
+	policy tagPolicy,
 	listTagsFn func(context.Context) ([]string, error),
 ) (string, error) {
 	tag := EmbeddedTag(ref)
@@ -155,10 +371,18 @@ func resolveTag(
 	switch {
 	case tag != "" && version != "":
 		return "", fmt.Errorf("%w: ref %q has tag %q, version %q", ErrRefContainsTag, ref, tag, version)
-	case version != "":
-		return version, nil
 	case tag != "":
+		if err := policy.checkLiteral(tag); err != nil {
+			return "", err
+		}
+
 		return tag, nil
+	case version != "" && isLiteralVersion(version):
+		if err := policy.checkLiteral(version); err != nil {
+			return "", err
+		}
+
+		return version, nil
 	}
 
 	tags, err := listTagsFn(ctx)
@@ -170,7 +394,16 @@ func resolveTag(
 		return "", fmt.Errorf("%w: %q", ErrNoTags, ref)
 	}
 
-	latest, err := latestSemver(tags)
+	if version != "" {
+		match, err := highestMatch(tags, version, policy)
+		if err != nil {
+			return "", fmt.Errorf("unable to resolve version %q for %q: %w", version, ref, err)
+		}
+
+		return match, nil
+	}
+
+	latest, err := latestSemver(tags, policy)
 	if err != nil {
 		return "", fmt.Errorf("unable to resolve latest version for %q: %w", ref, err)
 	}
@@ -178,12 +411,12 @@ func resolveTag(
 	return latest.Original(), nil
 }
 
-func latestSemver(tags []string) (*semver.Version, error) {
+func latestSemver(tags []string, policy tagPolicy) (*semver.Version, error) {
 	versions := make([]*semver.Version, 0, len(tags))
 
 	for _, t := range tags {
 		v, err := semver.NewVersion(t)
-		if err != nil {
+		if err != nil || !policy.allows(v) {
 			continue
 		}
 
@@ -199,6 +432,90 @@ func latestSemver(tags []string) (*semver.Version, error) {
 	return versions[0], nil
 }
 
+// isLiteralVersion reports whether version names a single tag rather than a
+// range: either a strict semver version such as "1.2.3" or "1.2.3+build.1",
+// or a string that is not a valid constraint at all, such as "latest".
+func isLiteralVersion(version string) bool {
+	if _, err := semver.StrictNewVersion(version); err == nil {
+		return true
+	}
+
+	_, err := semver.NewConstraint(version)
+
+	return err != nil
+}
+
+// highestMatch returns the tag equal to version if one exists, and otherwise
+// the highest eligible semver tag satisfying version as a constraint. Tags are
+// expected in their semver form, with "_" already mapped back to "+".
+func highestMatch(tags []string, version string, policy tagPolicy) (string, error) {
+	if slices.Contains(tags, version) {
+		if err := policy.checkLiteral(version); err != nil {
+			return "", err
+		}
+
+		return version, nil
+	}
+
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint %q: %w", version, err)
+	}
+
+	constraint.IncludePrerelease = policy.prereleases
+
+	var best *semver.Version
+
+	for _, t := range tags {
+		v, err := semver.NewVersion(t)
+		if err != nil || !constraint.Check(v) || !policy.allowsFloor(v) {
+			continue
+		}
+
+		if best == nil || v.GreaterThan(best) {
+			best = v
+		}
+	}
+
+	if best == nil {
+		return "", fmt.Errorf("%w: %q", ErrNoMatchingTag, version)
+	}
+
+	return best.Original(), nil
+}
+
+// allows reports whether v is eligible as the latest version.
+func (p tagPolicy) allows(v *semver.Version) bool {
+	if v.Prerelease() != "" && !p.prereleases {
+		return false
+	}
+
+	return p.allowsFloor(v)
+}
+
+func (p tagPolicy) allowsFloor(v *semver.Version) bool {
+	return p.minVersion == nil || !v.LessThan(p.minVersion)
+}
+
+// checkLiteral rejects an explicitly requested tag below the minimum version.
+// Prereleases are always allowed when asked for by name, and tags that are not
+// semver cannot be compared and are accepted.
+func (p tagPolicy) checkLiteral(tag string) error {
+	v, err := semver.NewVersion(tag)
+	if err != nil || p.allowsFloor(v) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q is lower than %q", ErrVersionBelowMinimum, tag, p.minVersion.Original())
+}
+
+// ociTag maps a semver version to its OCI tag form. OCI tags cannot contain
+// "+", so Helm pushes build metadata with "_" instead.
+// See https://github.com/helm/helm/issues/10166
+func ociTag(version string) string {
+	return strings.ReplaceAll(version, "+", "_")
+}
+
 // EmbeddedTag extracts a tag from an OCI ref if present.
 // e.g. "oci://registry/chart:1.0" returns "1.0", "oci://registry/chart" returns "".
 // Port numbers (e.g. "localhost:5000/chart") are not mistaken for tags.
@@ -232,3 +549,15 @@ func EmbeddedDigest(ref string) godigest.Digest {
 
 	return digested.Digest()
 }
+
+// RepositoryRef returns the normalized "registry/path" form of an OCI ref with
+// any oci:// prefix, tag and digest removed.
+// e.g. "oci://registry/chart:1.0" returns "registry/chart".
+func RepositoryRef(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(strings.TrimPrefix(ref, "oci://"))
+	if err != nil {
+		return "", fmt.Errorf("invalid OCI reference %q: %w", ref, err)
+	}
+
+	return reference.Domain(named) + "/" + reference.Path(named), nil
+}
@@ -1,12 +1,17 @@
 package container_test
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/opencontainers/go-digest"
@@ -81,6 +86,33 @@ func TestNewClient(t *testing.T) {
 	})
 }
 
+func TestRepositoryRef(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should strip prefix, tag and digest", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		for _, ref := range []string{
+			"oci://registry.example.com/charts/nginx",
+			"oci://registry.example.com/charts/nginx:1.0.0",
+			"registry.example.com/charts/nginx@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		} {
+			repoRef, err := container.RepositoryRef(ref)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(repoRef).To(Equal("registry.example.com/charts/nginx"))
+		}
+	})
+
+	t.Run("should error on invalid reference", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := container.RepositoryRef("oci://INVALID/Ref")
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
 func TestClient_Pull(t *testing.T) {
 	t.Parallel()
 
@@ -179,6 +211,131 @@ func TestClient_PullDigest(t *testing.T) {
 	})
 }
 
+func TestClient_Layers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should resolve chart layer descriptor by tag and fetch it", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		chartContent := []byte("layer-chart-content")
+		srv := newMockOCIRegistry(t, chartContent)
+
+		client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		layers, err := client.Layers(t.Context(), "1.0.0")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(layers.Chart.Digest).To(Equal(digest.FromBytes(chartContent)))
+		g.Expect(layers.Provenance).To(BeNil())
+
+		data, err := client.FetchLayer(t.Context(), layers.Chart)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(Equal(chartContent))
+	})
+
+	t.Run("should resolve chart layer descriptor by manifest digest", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		lastContent := []byte("last-layer-by-digest")
+		srv := newMockOCIRegistryMultiLayer(t, []byte("first-layer-by-digest"), lastContent)
+
+		client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		layers, err := client.LayersDigest(t.Context(), srv.manifestDigest)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(layers.Chart.Digest).To(Equal(digest.FromBytes(lastContent)))
+	})
+
+	t.Run("should return the provenance layer when present", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		chartContent := []byte("signed-chart-content")
+		provContent := []byte("signed-chart-provenance")
+		chartDigest := digest.FromBytes(chartContent)
+		provDigest := digest.FromBytes(provContent)
+
+		manifest := ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Layers: []ocispec.Descriptor{
+				{
+					MediaType: "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+					Digest:    chartDigest,
+					Size:      int64(len(chartContent)),
+				},
+				{
+					MediaType: "application/vnd.cncf.helm.chart.provenance.v1.prov",
+					Digest:    provDigest,
+					Size:      int64(len(provContent)),
+				},
+			},
+		}
+
+		srv := newMockOCIServer(t, manifest, map[digest.Digest][]byte{
+			chartDigest: chartContent,
+			provDigest:  provContent,
+		}, nil)
+
+		client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		layers, err := client.Layers(t.Context(), "1.0.0")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(layers.Chart.Digest).To(Equal(chartDigest))
+		g.Expect(layers.Provenance).ToNot(BeNil())
+
+		data, err := client.FetchLayer(t.Context(), *layers.Provenance)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(Equal(provContent))
+	})
+
+	t.Run("should error on empty tag", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client, err := container.NewClient("registry.example.com/charts/nginx")
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.Layers(t.Context(), "")
+		g.Expect(errors.Is(err, container.ErrEmptyTag)).To(BeTrue())
+	})
+}
+
+func TestClient_Pull_DigestMismatch(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	original := []byte("original-chart-content")
+	tampered := []byte("tampered-chart-content")
+	chartDigest := digest.FromBytes(original)
+
+	manifest := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Layers: []ocispec.Descriptor{{
+			MediaType: "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+			Digest:    chartDigest,
+			Size:      int64(len(original)),
+		}},
+	}
+
+	srv := newMockOCIServer(t, manifest, map[digest.Digest][]byte{chartDigest: tampered}, nil)
+
+	client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = client.Pull(t.Context(), "1.0.0")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(container.IsDigestMismatchError(err)).To(BeTrue())
+
+	var mismatch *container.DigestMismatchError
+	g.Expect(errors.As(err, &mismatch)).To(BeTrue())
+	g.Expect(mismatch.Expected).To(Equal(chartDigest))
+	g.Expect(mismatch.Actual).To(Equal(digest.FromBytes(tampered)))
+}
+
 func TestClient_PullIndex(t *testing.T) {
 	t.Parallel()
 
@@ -275,6 +432,411 @@ func TestClient_Tags(t *testing.T) {
 	})
 }
 
+func TestClient_ResolveTag(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"1.3.9", "1.4.0", "1.4.2", "1.5.0", "2.0.0", "2.1.0_build.1", "3.0.0-rc.1"}
+
+	tests := []struct {
+		name    string
+		version string
+		want    string
+	}{
+		{name: "should pick highest patch for tilde constraint", version: "~1.4", want: "1.4.2"},
+		{name: "should pick highest match for range constraint", version: ">=1.4.0 <2", want: "1.5.0"},
+		{name: "should map underscore tags back to build metadata", version: "^2.1", want: "2.1.0+build.1"},
+		{name: "should prefer tag equal to the constraint", version: "1.4.0", want: "1.4.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			srv := newMockOCIRegistryWithTags(t, tags)
+
+			client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			tag, err := client.ResolveTag(t.Context(), tt.version)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(tag).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("should return literal versions without listing tags", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		// The registry serves no tag list, so any listing would fail.
+		srv := newMockOCIRegistry(t, []byte("chart"))
+
+		client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		for _, version := range []string{"1.0.0", "1.0.0+build.1", "latest"} {
+			tag, err := client.ResolveTag(t.Context(), version)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(tag).To(Equal(version))
+		}
+	})
+
+	t.Run("should return ErrNoMatchingTag when no tag satisfies the constraint", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newMockOCIRegistryWithTags(t, tags)
+
+		client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.ResolveTag(t.Context(), "~4.0")
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, container.ErrNoMatchingTag)).To(BeTrue())
+	})
+}
+
+func TestClient_ResolveTag_Policy(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"1.0.0", "1.5.0", "2.0.0", "3.0.0-rc.1"}
+
+	tests := []struct {
+		name    string
+		version string
+		opts    []container.ClientOption
+		want    string
+	}{
+		{name: "should exclude prereleases from latest by default", want: "2.0.0"},
+		{
+			name: "should include prereleases in latest when allowed",
+			opts: []container.ClientOption{container.WithPrereleases(true)},
+			want: "3.0.0-rc.1",
+		},
+		{
+			name:    "should include prereleases in constraints when allowed",
+			version: ">=2",
+			opts:    []container.ClientOption{container.WithPrereleases(true)},
+			want:    "3.0.0-rc.1",
+		},
+		{
+			name:    "should skip tags below the minimum version",
+			version: ">=1",
+			opts:    []container.ClientOption{container.WithMinVersion("1.2.0")},
+			want:    "2.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			srv := newMockOCIRegistryWithTags(t, tags)
+
+			client, err := container.NewClient(srv.ref, append(tt.opts, container.WithPlainHTTP(true))...)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			tag, err := client.ResolveTag(t.Context(), tt.version)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(tag).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("should reject a literal version below the minimum version", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client, err := container.NewClient("registry.example.com/charts/nginx", container.WithMinVersion("2.0.0"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.ResolveTag(t.Context(), "1.0.0")
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, container.ErrVersionBelowMinimum)).To(BeTrue())
+	})
+
+	t.Run("should reject an invalid minimum version", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := container.NewClient("registry.example.com/charts/nginx", container.WithMinVersion("latest"))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestClient_Pull_BuildMetadata(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	content := []byte("build-metadata-chart")
+	srv := newMockOCIRegistry(t, content)
+
+	client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	data, err := client.Pull(t.Context(), "1.0.0+build.1")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).To(Equal(content))
+}
+
+func TestClient_Pull_TLS(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("tls-chart")
+	plain := newMockOCIRegistry(t, content)
+
+	srv := httptest.NewTLSServer(plain.Config.Handler)
+	t.Cleanup(srv.Close)
+
+	ref := srv.Listener.Addr().String() + "/test/chart"
+
+	t.Run("should trust the configured root CAs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		roots := x509.NewCertPool()
+		roots.AddCert(srv.Certificate())
+
+		client, err := container.NewClient(ref, container.WithTLSConfig(&tls.Config{
+			RootCAs:    roots,
+			MinVersion: tls.VersionTLS12,
+		}))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := client.Pull(t.Context(), "1.0.0")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(Equal(content))
+	})
+
+	t.Run("should return CertificateError for an untrusted registry", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client, err := container.NewClient(ref)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.Pull(t.Context(), "1.0.0")
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(container.IsCertificateError(err)).To(BeTrue())
+
+		var certErr *container.CertificateError
+		g.Expect(errors.As(err, &certErr)).To(BeTrue())
+		g.Expect(certErr.Host).To(Equal(srv.Listener.Addr().String()))
+	})
+}
+
+func TestClient_HTTPClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should send registry requests through the transport", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		content := []byte("transport-chart")
+		srv := newMockOCIRegistry(t, content)
+
+		var requests atomic.Int32
+
+		transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			requests.Add(1)
+
+			return http.DefaultTransport.RoundTrip(r)
+		})
+
+		client, err := container.NewClient(srv.ref, container.WithPlainHTTP(true), container.WithTransport(transport))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := client.Pull(t.Context(), "1.0.0")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(Equal(content))
+		g.Expect(requests.Load()).To(BeNumerically(">=", 2))
+	})
+
+	t.Run("should use the HTTP client instead of the TLS configuration", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		content := []byte("http-client-chart")
+		plain := newMockOCIRegistry(t, content)
+
+		srv := httptest.NewTLSServer(plain.Config.Handler)
+		t.Cleanup(srv.Close)
+
+		client, err := container.NewClient(
+			srv.Listener.Addr().String()+"/test/chart",
+			container.WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}),
+			container.WithHTTPClient(srv.Client()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := client.Pull(t.Context(), "1.0.0")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(Equal(content))
+	})
+}
+
+func TestClient_Pull_LocalhostFallback(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("localhost-chart")
+	srv := newMockOCIRegistry(t, content)
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []string{srv.ref, "localhost:" + port + "/test/chart"} {
+		t.Run("should fall back to plain HTTP for "+ref, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			client, err := container.NewClient(ref)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			data, err := client.Pull(t.Context(), "1.0.0")
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(data).To(Equal(content))
+		})
+	}
+}
+
+func TestClient_Pull_TokenAuth(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("token-chart")
+
+	// newTokenRegistry serves the mock registry only to requests carrying
+	// the access token, and exchanges refreshToken for it at /token.
+	newTokenRegistry := func(t *testing.T, refreshToken string) string {
+		t.Helper()
+
+		registry := newMockOCIRegistry(t, content)
+
+		var srv *httptest.Server
+
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/token" {
+				if err := r.ParseForm(); err != nil || r.PostForm.Get("refresh_token") != refreshToken {
+					http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+
+					return
+				}
+
+				_, _ = w.Write([]byte(`{"access_token":"access-token"}`))
+
+				return
+			}
+
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, srv.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			registry.Config.Handler.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+
+		return srv.Listener.Addr().String() + "/test/chart"
+	}
+
+	t.Run("should authenticate with an access token", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client, err := container.NewClient(
+			newTokenRegistry(t, "unused"),
+			container.WithPlainHTTP(true),
+			container.WithAccessToken("access-token"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := client.Pull(t.Context(), "1.0.0")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(Equal(content))
+	})
+
+	t.Run("should exchange a refresh token for an access token", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client, err := container.NewClient(
+			newTokenRegistry(t, "refresh-token"),
+			container.WithPlainHTTP(true),
+			container.WithRefreshToken("refresh-token"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := client.Pull(t.Context(), "1.0.0")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(data).To(Equal(content))
+	})
+
+	t.Run("should fail without a token", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		client, err := container.NewClient(
+			newTokenRegistry(t, "refresh-token"),
+			container.WithPlainHTTP(true),
+			container.WithCredential("user", "pass"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = client.Pull(t.Context(), "1.0.0")
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestClient_Pull_Header(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	content := []byte("header-chart")
+	registry := newMockOCIRegistry(t, content)
+
+	// Blobs are redirected to a storage host that must not see the header.
+	var leaked atomic.Bool
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked.Store(r.Header.Get("X-Api-Key") != "")
+		registry.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(storage.Close)
+
+	var missing atomic.Bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" {
+			missing.Store(true)
+		}
+
+		if strings.Contains(r.URL.Path, "/blobs/") {
+			http.Redirect(w, r, storage.URL+r.URL.Path, http.StatusTemporaryRedirect)
+
+			return
+		}
+
+		registry.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := container.NewClient(
+		srv.Listener.Addr().String()+"/test/chart",
+		container.WithPlainHTTP(true),
+		container.WithHeader("X-Api-Key", "key"),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	data, err := client.Pull(t.Context(), "1.0.0")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).To(Equal(content))
+	g.Expect(missing.Load()).To(BeFalse(), "header should be sent to the registry")
+	g.Expect(leaked.Load()).To(BeFalse(), "header should not leak to other hosts")
+}
+
 func TestClient_Pull_EmptyTag(t *testing.T) {
 	t.Parallel()
 	g := NewWithT(t)
@@ -963,3 +1525,10 @@ func newMockOCIServer(
 		manifestDigest: manifestDigest,
 	}
 }
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -2,7 +2,10 @@ package container
 
 import (
 	"errors"
+	"fmt"
 
+	"github.com/Masterminds/semver/v3"
+	godigest "github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -33,12 +36,75 @@ var ErrNoTags = errors.New("no tags found")
 // ErrNoValidSemverTag is returned when none of the registry tags are valid semver.
 var ErrNoValidSemverTag = errors.New("no valid semver tag found")
 
+// ErrNoMatchingTag is returned when no registry tag satisfies a version constraint.
+var ErrNoMatchingTag = errors.New("no tag matches version constraint")
+
+// ErrVersionBelowMinimum is returned when an explicitly requested version is
+// lower than the configured minimum version.
+var ErrVersionBelowMinimum = errors.New("version is below the minimum version")
+
 // ErrInvalidDescriptorSize is returned when a descriptor reports a non-positive size.
 var ErrInvalidDescriptorSize = errors.New("descriptor has invalid size")
 
 // ErrBlobTooLarge is returned when a blob exceeds the maximum allowed size.
 var ErrBlobTooLarge = errors.New("blob exceeds maximum allowed size")
 
+// DigestMismatchError is returned when downloaded content does not hash to
+// the digest it was requested by.
+type DigestMismatchError struct {
+	Expected godigest.Digest
+	Actual   godigest.Digest
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("digest mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// IsDigestMismatchError reports whether err or any error in its chain is a *DigestMismatchError.
+func IsDigestMismatchError(err error) bool {
+	var target *DigestMismatchError
+
+	return errors.As(err, &target)
+}
+
+// CertificateError is returned when a TLS handshake fails because a
+// certificate was rejected: the server certificate could not be verified
+// against the trusted CAs or does not match the host name, or the server
+// refused the client certificate.
+type CertificateError struct {
+	Host string
+	Err  error
+}
+
+func (e *CertificateError) Error() string {
+	return fmt.Sprintf("TLS certificate error for %s: %v", e.Host, e.Err)
+}
+
+func (e *CertificateError) Unwrap() error {
+	return e.Err
+}
+
+// IsCertificateError reports whether err or any error in its chain is a *CertificateError.
+func IsCertificateError(err error) bool {
+	var target *CertificateError
+
+	return errors.As(err, &target)
+}
+
+// ChartLayers holds the layer descriptors of a Helm chart artifact.
+// Provenance is nil when the chart was pushed without a signature.
+type ChartLayers struct {
+	Chart      ocispec.Descriptor
+	Provenance *ocispec.Descriptor
+}
+
+// tagPolicy decides which semver tags are eligible when resolving the latest
+// tag or a version constraint.
+type tagPolicy struct {
+	prereleases bool
+	minVersion  *semver.Version
+}
+
 type resolvedArtifact struct {
 	desc     ocispec.Descriptor
 	manifest ocispec.Manifest
@@ -19,7 +19,8 @@ func (e *ValidationError) Unwrap() error {
 }
 
 // LocateError indicates a failure to locate or download a chart.
-// These errors are potentially retryable (e.g. transient network failures).
+// These errors are potentially retryable (e.g. transient network failures);
+// see WithRetry to retry them automatically.
 type LocateError struct {
 	Chart   string
 	Repo    string
@@ -51,6 +52,24 @@ func (e *RenderError) Unwrap() error {
 	return e.Err
 }
 
+// LockError indicates that a source does not match the lockfile: its entry is
+// missing or stale. The inner Err wraps ErrLockMissing or ErrLockStale.
+// Renderer.UpdateLock refreshes the lockfile.
+type LockError struct {
+	Chart   string
+	Repo    string
+	Version string
+	Err     error
+}
+
+func (e *LockError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
+
 // IsValidationError reports whether err or any error in its chain is a *ValidationError.
 func IsValidationError(err error) bool {
 	var target *ValidationError
@@ -71,3 +90,10 @@ func IsRenderError(err error) bool {
 
 	return errors.As(err, &target)
 }
+
+// IsLockError reports whether err or any error in its chain is a *LockError.
+func IsLockError(err error) bool {
+	var target *LockError
+
+	return errors.As(err, &target)
+}
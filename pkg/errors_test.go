@@ -169,6 +169,17 @@ func TestErrorTypes(t *testing.T) {
 		g.Expect(helm.IsRenderError(errors.New("unrelated"))).To(BeFalse())
 	})
 
+	t.Run("IsLockError predicate", func(t *testing.T) {
+		g := NewWithT(t)
+
+		le := &helm.LockError{Chart: "x", Version: "~1.0", Err: helm.ErrLockMissing}
+		wrapped := fmt.Errorf("failed: %w", le)
+
+		g.Expect(helm.IsLockError(wrapped)).To(BeTrue())
+		g.Expect(errors.Is(wrapped, helm.ErrLockMissing)).To(BeTrue())
+		g.Expect(helm.IsLockError(errors.New("unrelated"))).To(BeFalse())
+	})
+
 	t.Run("LocateError from invalid chart path via Process", func(t *testing.T) {
 		g := NewWithT(t)
 
@@ -3,10 +3,15 @@ package helm
 import (
 	"context"
 	"fmt"
+	"io/fs"
+	"slices"
 	"sync"
+	"time"
 
+	"golang.org/x/sync/errgroup"
 	"helm.sh/helm/v4/pkg/chart/common"
 	commonutil "helm.sh/helm/v4/pkg/chart/common/util"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
 	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
 	"helm.sh/helm/v4/pkg/engine"
 	"helm.sh/helm/v4/pkg/helmpath"
@@ -22,15 +27,35 @@ import (
 
 const rendererType = "helm"
 
+// AnnotationSourceCommit records the git commit of charts located through a
+// "git+" reference. It is added along with the other source annotations.
+const AnnotationSourceCommit = "manifests.k8s-manifest-kit.io/source-commit"
+
 // Source defines a Helm chart source for rendering.
 type Source struct {
 	// Repo is the repository URL for chart lookup. Optional for local or OCI charts.
+	// Schemes other than http and https need a WithGetter or WithLocator for them.
 	Repo string
 
-	// Chart specifies the chart to render. Supports OCI references (oci://registry/chart:tag)
-	// or local filesystem paths. Required.
+	// Chart specifies the chart to render. Supports OCI references (oci://registry/chart:tag),
+	// local filesystem paths, "repo/chart" names resolved through the repositories file
+	// when Repo is empty, http(s) URLs of a chart archive (.tgz or .tar.gz) when Repo is
+	// empty, git references such as "git+https://host/repo//path/to/chart?ref=v1.2.0",
+	// or references with a scheme registered through WithLocator. With FS set, it is
+	// the path of the chart directory or archive within FS. Required unless Loaded is
+	// set, in which case it defaults to the chart name.
 	Chart string
 
+	// FS, when set, holds the chart instead of the local filesystem or a remote
+	// source, for example an embed.FS; Chart is the chart root within it and the
+	// locator is not used. Embed chart directories with the "all:" prefix so that
+	// files such as templates/_helpers.tpl are included. .helmignore is not applied.
+	FS fs.FS
+
+	// Loaded is an already-loaded chart to render as is, bypassing the locator.
+	// It must not be modified while the Renderer is in use.
+	Loaded *chart.Chart
+
 	// ReleaseName is the Helm release name used in template rendering metadata.
 	// Required for proper .Release.Name substitution in templates.
 	ReleaseName string
@@ -40,8 +65,15 @@ type Source struct {
 	ReleaseNamespace string
 
 	// ReleaseVersion constrains the chart version to fetch. Optional; uses latest if empty.
+	// Accepts an exact version or a semver constraint such as "~1.4" for both
+	// repository and OCI charts; OCI constraints are resolved by listing tags.
 	ReleaseVersion string
 
+	// ResolutionPolicy controls which chart versions ReleaseVersion may resolve
+	// to: prereleases, deprecated versions and a minimum version. Optional;
+	// the zero value excludes prereleases.
+	ResolutionPolicy locator.ResolutionPolicy
+
 	// Values provides template variable overrides during chart rendering.
 	// Function is called during rendering to obtain dynamic values.
 	// Merged with chart defaults via chartutil.ToRenderValues.
@@ -52,11 +84,43 @@ type Source struct {
 	// Optional; only needed for authenticated registries/repositories.
 	Credentials func(context.Context) (*locator.Credentials, error)
 
+	// TLS configures TLS for the repository or registry hosting the chart.
+	// Optional; overrides the renderer-wide WithTLSConfig.
+	TLS *locator.TLSConfig
+
+	// PlainHTTP talks to the OCI registry hosting the chart over HTTP instead
+	// of HTTPS, e.g. a local registry:2. Optional; registries on localhost
+	// fall back to HTTP automatically. Set TLS.InsecureSkipVerify to accept
+	// any certificate instead.
+	PlainHTTP bool
+
+	// Verify requires the chart to be signed: its provenance file must verify
+	// against the renderer keyring (see WithVerification). Verification is
+	// also enabled for every source by WithVerification itself.
+	Verify bool
+
 	// ProcessDependencies determines whether chart dependencies should be processed.
 	// If true, chartutil.ProcessDependencies will be called during rendering.
 	// Default is false.
 	ProcessDependencies bool
 
+	// BuildDependencies fetches the dependencies declared in Chart.yaml that are
+	// not vendored in the chart's charts/ directory, the way "helm dependency
+	// build" does, and adds them to the loaded chart. Versions pinned in
+	// Chart.lock win over Chart.yaml ranges, and a Chart.lock whose digest does
+	// not match Chart.yaml is rejected. Dependencies are located through the
+	// renderer-wide repositories, registries and credential providers; Source
+	// Credentials and ResolutionPolicy apply to the chart itself only.
+	// Default is false.
+	BuildDependencies bool
+
+	// RefreshInterval re-resolves the chart once it has been loaded for this
+	// long, so that long-lived renderers pick up new versions matching
+	// ReleaseVersion. Renders started before a refresh finish with the chart
+	// they started with. A negative value disables refreshes for this Source.
+	// Default: the renderer-wide RefreshInterval.
+	RefreshInterval time.Duration
+
 	// PostRenderers are source-specific post-renderers applied to this source's output
 	// before combining with other sources.
 	PostRenderers []types.PostRenderer
@@ -67,6 +131,28 @@ type Source struct {
 // the source or false to skip it. Evaluated before rendering.
 type SourceSelector = func(ctx context.Context, source Source) (bool, error)
 
+// SourceResult describes the chart a Source was rendered from.
+type SourceResult struct {
+	// Index is the position of the Source in the inputs passed to New.
+	Index int
+
+	// Source is the configured Source.
+	Source Source
+
+	// Result holds the resolved version, archive digest, download URL or OCI
+	// reference and Chart.yaml metadata of the loaded chart.
+	Result locator.Result
+
+	// Dependencies holds what the dependencies fetched by BuildDependencies
+	// resolved to, in Chart.yaml order. Vendored dependencies are not listed.
+	Dependencies []locator.Result
+
+	// RefreshError is the error of the last failed interval refresh, in which
+	// case the previously loaded chart is still rendered. It is reset by the
+	// next successful refresh.
+	RefreshError error
+}
+
 // Renderer handles Helm rendering operations.
 // It implements types.Renderer.
 //
@@ -78,6 +164,7 @@ type Renderer struct {
 	helmEngine engine.Engine
 	opts       RendererOptions
 	cache      cache.Interface[[]unstructured.Unstructured]
+	lock       *lockState
 }
 
 // New creates a new Helm Renderer with the given inputs and options.
@@ -89,21 +176,41 @@ func New(inputs []Source, opts ...RendererOption) (*Renderer, error) {
 		RepositoryConfig: helmpath.ConfigPath("repositories.yaml"),
 		RepositoryCache:  helmpath.CachePath("repository"),
 		ContentCache:     helmpath.CachePath("content"),
+		Keyring:          defaultKeyring(),
 	}
 
 	for _, opt := range opts {
 		opt.ApplyTo(&rendererOpts)
 	}
 
+	var lock *lockState
+
+	switch {
+	case rendererOpts.Lockfile != "":
+		var err error
+
+		lock, err = readLockfile(rendererOpts.Lockfile, rendererOpts.FrozenLockfile)
+		if err != nil {
+			return nil, err
+		}
+	case rendererOpts.FrozenLockfile:
+		return nil, &ValidationError{Field: "Lockfile", Err: ErrLockfileNotSet}
+	}
+
 	holders := make([]*sourceHolder, len(inputs))
 	for i := range inputs {
 		holders[i] = &sourceHolder{
 			Source: inputs[i],
 			mu:     &sync.RWMutex{},
+			loadMu: &sync.Mutex{},
+			lock:   lock,
+		}
+		if holders[i].Chart == "" && holders[i].Loaded != nil && holders[i].Loaded.Metadata != nil {
+			holders[i].Chart = holders[i].Loaded.Name()
 		}
 		if err := holders[i].Validate(); err != nil {
 			return nil, fmt.Errorf("validation failed for source[%d] (chart: %q, release: %q): %w",
-				i, inputs[i].Chart, inputs[i].ReleaseName, err)
+				i, holders[i].Chart, inputs[i].ReleaseName, err)
 		}
 	}
 
@@ -115,55 +222,50 @@ func New(inputs []Source, opts ...RendererOption) (*Renderer, error) {
 		},
 		opts:  rendererOpts,
 		cache: newCache(rendererOpts.CacheOptions),
+		lock:  lock,
 	}
 
 	return r, nil
 }
 
 // Process executes the rendering logic for all configured inputs.
-// It implements the types.Renderer interface.
+// It implements the types.Renderer interface. Up to WithConcurrency sources
+// are located and rendered in parallel; objects are returned in source order
+// regardless. The first error cancels the sources still rendering.
 // This method is safe for concurrent use.
 func (r *Renderer) Process(ctx context.Context, renderTimeValues types.Values) ([]unstructured.Unstructured, error) {
-	allObjects := make([]unstructured.Unstructured, 0)
+	// Each source renders into its own slot, so that the output keeps the
+	// input order however many sources render in parallel
+	rendered := make([][]unstructured.Unstructured, len(r.inputs))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(r.opts.Concurrency, 1))
 
 	for i := range r.inputs {
-		selected, err := pipeline.ApplySourceSelectors(ctx, r.inputs[i].Source, r.opts.SourceSelectors)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"source selector error for helm chart %s (release: %s): %w",
-				r.inputs[i].Chart,
-				r.inputs[i].ReleaseName,
-				err,
-			)
+		// Stop scheduling sources once one of them failed
+		if groupCtx.Err() != nil {
+			break
 		}
 
-		if !selected {
-			continue
-		}
+		group.Go(func() error {
+			objects, err := r.processSource(groupCtx, r.inputs[i], renderTimeValues.DeepClone())
+			if err != nil {
+				return err
+			}
 
-		sValues := renderTimeValues.DeepClone()
+			rendered[i] = objects
 
-		objects, err := r.processSingle(ctx, r.inputs[i], sValues)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"error rendering helm chart %s (release: %s): %w",
-				r.inputs[i].Chart,
-				r.inputs[i].ReleaseName,
-				err,
-			)
-		}
+			return nil
+		})
+	}
 
-		objects, err = pipeline.ApplyPostRenderers(ctx, objects, r.inputs[i].PostRenderers)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"source post-renderer error for helm chart %s (release: %s): %w",
-				r.inputs[i].Chart,
-				r.inputs[i].ReleaseName,
-				err,
-			)
-		}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
 
-		allObjects = append(allObjects, objects...)
+	allObjects := slices.Concat(rendered...)
+	if allObjects == nil {
+		allObjects = make([]unstructured.Unstructured, 0)
 	}
 
 	chain := types.BuildPostRendererChain(r.opts.Filters, r.opts.Transformers, r.opts.PostRenderers)
@@ -176,11 +278,80 @@ func (r *Renderer) Process(ctx context.Context, renderTimeValues types.Values) (
 	return result, nil
 }
 
+// processSource renders a single source if its SourceSelectors select it and
+// applies its post-renderers. Sources that are not selected yield no objects.
+func (r *Renderer) processSource(
+	ctx context.Context,
+	holder *sourceHolder,
+	renderTimeValues types.Values,
+) ([]unstructured.Unstructured, error) {
+	selected, err := pipeline.ApplySourceSelectors(ctx, holder.Source, r.opts.SourceSelectors)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"source selector error for helm chart %s (release: %s): %w",
+			holder.Chart,
+			holder.ReleaseName,
+			err,
+		)
+	}
+
+	if !selected {
+		return nil, nil
+	}
+
+	objects, err := r.processSingle(ctx, holder, renderTimeValues)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error rendering helm chart %s (release: %s): %w",
+			holder.Chart,
+			holder.ReleaseName,
+			err,
+		)
+	}
+
+	objects, err = pipeline.ApplyPostRenderers(ctx, objects, holder.PostRenderers)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"source post-renderer error for helm chart %s (release: %s): %w",
+			holder.Chart,
+			holder.ReleaseName,
+			err,
+		)
+	}
+
+	return objects, nil
+}
+
 // Name returns the renderer type identifier.
 func (r *Renderer) Name() string {
 	return rendererType
 }
 
+// Results returns what each Source resolved to, in input order. Charts are
+// loaded lazily by Process, so sources that have not been rendered yet, for
+// example because a SourceSelector skipped them, are omitted.
+// This method is safe for concurrent use.
+func (r *Renderer) Results() []SourceResult {
+	results := make([]SourceResult, 0, len(r.inputs))
+
+	for i, holder := range r.inputs {
+		loaded := holder.snapshot()
+		if loaded == nil {
+			continue
+		}
+
+		results = append(results, SourceResult{
+			Index:        i,
+			Source:       holder.Source,
+			Result:       loaded.result,
+			Dependencies: loaded.dependencies,
+			RefreshError: loaded.refreshErr,
+		})
+	}
+
+	return results
+}
+
 func (r *Renderer) values(
 	ctx context.Context,
 	holder *sourceHolder,
@@ -212,6 +383,7 @@ func (r *Renderer) values(
 func (r *Renderer) processValues(
 	ctx context.Context,
 	holder *sourceHolder,
+	helmChart *chart.Chart,
 	renderTimeValues types.Values,
 ) (common.Values, error) {
 	values, err := r.values(ctx, holder, renderTimeValues)
@@ -225,7 +397,7 @@ func (r *Renderer) processValues(
 	}
 
 	if holder.ProcessDependencies {
-		if err := chartutil.ProcessDependencies(holder.chart, map[string]any(values)); err != nil {
+		if err := chartutil.ProcessDependencies(helmChart, map[string]any(values)); err != nil {
 			return nil, fmt.Errorf(
 				"failed to process dependencies for chart %q (release %q): %w",
 				holder.Chart,
@@ -236,7 +408,7 @@ func (r *Renderer) processValues(
 	}
 
 	renderValues, err := commonutil.ToRenderValues(
-		holder.chart,
+		helmChart,
 		map[string]any(values),
 		common.ReleaseOptions{
 			Name:      holder.ReleaseName,
@@ -266,13 +438,14 @@ func (r *Renderer) processSingle(
 	holder *sourceHolder,
 	renderTimeValues types.Values,
 ) ([]unstructured.Unstructured, error) {
-	// Load chart if not already loaded (thread-safe lazy loading)
-	chart, err := holder.LoadChart(ctx, r.opts.RepositoryCache)
+	// Load chart if not already loaded (thread-safe lazy loading). The
+	// snapshot is used for the whole render, even if a refresh replaces it
+	loaded, err := holder.LoadChart(ctx, &r.opts)
 	if err != nil {
 		return nil, err
 	}
 
-	renderValues, err := r.processValues(ctx, holder, renderTimeValues)
+	renderValues, err := r.processValues(ctx, holder, loaded.chart, renderTimeValues)
 	if err != nil {
 		return nil, &RenderError{Chart: holder.Chart, ReleaseName: holder.ReleaseName, Err: err}
 	}
@@ -281,6 +454,8 @@ func (r *Renderer) processSingle(
 		Chart:          holder.Chart,
 		ReleaseName:    holder.ReleaseName,
 		ReleaseVersion: holder.ReleaseVersion,
+		Digest:         loaded.result.Digest,
+		Generation:     loaded.generation,
 		Values:         renderValues,
 	}
 
@@ -299,7 +474,7 @@ func (r *Renderer) processSingle(
 		return nil, fmt.Errorf("context cancelled before render: %w", err)
 	}
 
-	files, err := r.helmEngine.Render(chart, renderValues)
+	files, err := r.helmEngine.Render(loaded.chart, renderValues)
 	if err != nil {
 		return nil, &RenderError{
 			Chart:       holder.Chart,
@@ -312,13 +487,13 @@ func (r *Renderer) processSingle(
 
 	// Process CRDs before other resources to ensure custom resource definitions
 	// are available if any rendered templates reference custom resources
-	crdObjects, err := r.processCRDs(chart, holder)
+	crdObjects, err := r.processCRDs(loaded, holder)
 	if err != nil {
 		return nil, &RenderError{Chart: holder.Chart, ReleaseName: holder.ReleaseName, Err: err}
 	}
 	result = append(result, crdObjects...)
 
-	templateObjects, err := r.processRenderedTemplates(files, holder)
+	templateObjects, err := r.processRenderedTemplates(files, holder, loaded)
 	if err != nil {
 		return nil, &RenderError{Chart: holder.Chart, ReleaseName: holder.ReleaseName, Err: err}
 	}
@@ -15,12 +15,23 @@ type chartSpec struct {
 	Chart          string
 	ReleaseName    string
 	ReleaseVersion string
-	Values         common.Values
+
+	// Digest identifies the chart contents where known, so that sources
+	// sharing a Chart name, such as charts read from different FS, do not
+	// share cache entries.
+	Digest string
+
+	// Generation counts the charts a source loaded, so that entries rendered
+	// from a chart replaced by a refresh are no longer hit.
+	Generation uint64
+	Values     common.Values
 }
 
 // FastCacheKeyFunc generates cache keys based only on chart identity, ignoring values.
 // This provides significantly better cache performance but means all renders of the
 // same chart+release+version will share cached results regardless of values.
+// The chart digest is part of the key when known, e.g. for FS and Loaded sources,
+// and so is the generation of charts replaced by a refresh.
 //
 // Use this when:
 //   - Values are static and don't change between renders
@@ -31,7 +42,17 @@ type chartSpec struct {
 //	helm.WithCache(cache.WithKeyFunc(helm.FastCacheKeyFunc))
 func FastCacheKeyFunc(key any) string {
 	if spec, ok := key.(chartSpec); ok {
-		return fmt.Sprintf("%s:%s:%s", spec.Chart, spec.ReleaseName, spec.ReleaseVersion)
+		k := fmt.Sprintf("%s:%s:%s", spec.Chart, spec.ReleaseName, spec.ReleaseVersion)
+
+		if spec.Digest != "" {
+			k += "@" + spec.Digest
+		}
+
+		if spec.Generation > 1 {
+			k += fmt.Sprintf("#%d", spec.Generation)
+		}
+
+		return k
 	}
 
 	return ""
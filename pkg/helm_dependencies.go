@@ -0,0 +1,263 @@
+package helm
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	godigest "github.com/opencontainers/go-digest"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+)
+
+var (
+	// ErrLockOutOfSync is returned when the digest in Chart.lock does not match
+	// the dependencies declared in Chart.yaml.
+	ErrLockOutOfSync = errors.New("dependency lock is out of sync with Chart.yaml")
+
+	// ErrDependencyNotVendored is returned when a dependency that cannot be
+	// fetched, because it has no repository or refers to a local path outside a
+	// chart directory, is missing from the chart's charts/ directory.
+	ErrDependencyNotVendored = errors.New("dependency is not vendored in charts/")
+)
+
+// buildDependencies fetches the dependencies declared by c that are not
+// vendored yet and adds them to c. Vendored dependencies are left alone, so
+// charts packaged with their dependencies are not fetched again.
+func (h *sourceHolder) buildDependencies(
+	ctx context.Context,
+	opts *RendererOptions,
+	c *chart.Chart,
+	result locator.Result,
+) ([]locator.Result, error) {
+	if c.Metadata == nil || len(c.Metadata.Dependencies) == 0 {
+		return nil, nil
+	}
+
+	declared, err := resolveDependencyRepos(c.Metadata.Dependencies, opts.RepositoryConfig)
+	if err != nil {
+		return nil, &LocateError{Chart: h.Chart, Repo: h.Repo, Version: h.ReleaseVersion, Err: err}
+	}
+
+	locked, err := lockedVersions(c, declared)
+	if err != nil {
+		return nil, &LocateError{Chart: h.Chart, Repo: h.Repo, Version: h.ReleaseVersion, Err: err}
+	}
+
+	vendored := make(map[string]bool, len(c.Dependencies()))
+	for _, sub := range c.Dependencies() {
+		vendored[sub.Name()] = true
+	}
+
+	results := make([]locator.Result, 0, len(declared))
+
+	for _, dep := range declared {
+		if vendored[dep.Name] {
+			continue
+		}
+
+		version := dep.Version
+		if v, ok := locked[dep.Name]; ok {
+			version = v
+		}
+
+		sub, depResult, err := h.fetchDependency(ctx, opts, dep, version, chartDir(result))
+		if err != nil {
+			return nil, &LocateError{
+				Chart:   h.Chart,
+				Repo:    h.Repo,
+				Version: h.ReleaseVersion,
+				Err: fmt.Errorf(
+					"unable to build dependency (repository: %s, name: %s, version: %s): %w",
+					dep.Repository,
+					dep.Name,
+					version,
+					err,
+				),
+			}
+		}
+
+		c.AddDependency(sub)
+		vendored[dep.Name] = true
+
+		results = append(results, depResult)
+	}
+
+	return results, nil
+}
+
+// fetchDependency locates and loads a single dependency at version.
+func (h *sourceHolder) fetchDependency(
+	ctx context.Context,
+	opts *RendererOptions,
+	dep *chart.Dependency,
+	version string,
+	dir string,
+) (*chart.Chart, locator.Result, error) {
+	name, repoURL, err := dependencyRef(dep, dir)
+	if err != nil {
+		return nil, locator.Result{}, err
+	}
+
+	req := h.locatorRequest(opts)
+	req.Name = name
+	req.RepoURL = repoURL
+	req.Version = version
+	req.ResolutionPolicy = locator.ResolutionPolicy{}
+	req.Credentials = nil
+	req.TLS = h.dependencyTLS(opts, cmp.Or(repoURL, name))
+
+	result, err := charts.locate(ctx, req)
+	if err != nil {
+		return nil, locator.Result{}, fmt.Errorf("unable to locate dependency: %w", err)
+	}
+
+	sub, err := charts.load(result)
+	if err != nil {
+		return nil, locator.Result{}, fmt.Errorf("failed to load dependency: %w", err)
+	}
+
+	if result.Metadata == nil {
+		result.Metadata = sub.Metadata
+	}
+
+	return sub, result, nil
+}
+
+// dependencyTLS returns the TLS settings of the source for a dependency at ref
+// when it is hosted on the host of the source's chart. Dependencies on other
+// hosts get none, so that the repositories file and registry settings of
+// their own host apply.
+func (h *sourceHolder) dependencyTLS(opts *RendererOptions, ref string) *locator.TLSConfig {
+	host := refHost(ref)
+	if host == "" || (host != refHost(h.Repo) && host != refHost(h.Chart)) {
+		return nil
+	}
+
+	return cmp.Or(h.TLS, opts.TLS)
+}
+
+// refHost returns the host of a URL or "oci://" reference, or "" for local
+// paths and repository aliases.
+func refHost(ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+// resolveDependencyRepos returns copies of deps with "@name" and
+// "alias:name" repositories replaced by their URL from the repositories
+// file, as Helm does before digesting and resolving dependencies.
+func resolveDependencyRepos(deps []*chart.Dependency, repoConfig string) ([]*chart.Dependency, error) {
+	resolved := make([]*chart.Dependency, 0, len(deps))
+
+	for _, dep := range deps {
+		if dep == nil {
+			continue
+		}
+
+		d := *dep
+
+		name, isAlias := strings.CutPrefix(d.Repository, "@")
+		if !isAlias {
+			name, isAlias = strings.CutPrefix(d.Repository, "alias:")
+		}
+
+		if isAlias {
+			repoURL, err := locator.RepositoryURL(repoConfig, name)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve repository of dependency %s: %w", d.Name, err)
+			}
+
+			d.Repository = repoURL
+		}
+
+		resolved = append(resolved, &d)
+	}
+
+	return resolved, nil
+}
+
+// dependencyRef maps the repository of a Chart.yaml dependency to the chart
+// name and repository URL of a locator request. Repositories follow Helm:
+// an http(s) repository URL, an "oci://" registry path or a "file://" path
+// relative to the chart directory dir.
+func dependencyRef(dep *chart.Dependency, dir string) (string, string, error) {
+	repo := dep.Repository
+
+	switch {
+	case repo == "":
+		return "", "", fmt.Errorf("%w: %s has no repository", ErrDependencyNotVendored, dep.Name)
+	case strings.HasPrefix(repo, "file://"):
+		if dir == "" {
+			return "", "", fmt.Errorf("%w: %s refers to %s outside a chart directory", ErrDependencyNotVendored, dep.Name, repo)
+		}
+
+		return filepath.Join(dir, strings.TrimPrefix(repo, "file://")), "", nil
+	case strings.HasPrefix(repo, "oci://"):
+		return strings.TrimSuffix(repo, "/") + "/" + dep.Name, "", nil
+	default:
+		return dep.Name, repo, nil
+	}
+}
+
+// lockedVersions returns the versions pinned in Chart.lock by dependency
+// name, after checking that the lock digest still matches the declared
+// dependencies. Charts without a lock yield no pinned versions.
+func lockedVersions(c *chart.Chart, declared []*chart.Dependency) (map[string]string, error) {
+	if c.Lock == nil {
+		return map[string]string{}, nil
+	}
+
+	digest, err := lockDigest(declared, c.Lock.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	if digest != c.Lock.Digest {
+		return nil, fmt.Errorf("%w (chart: %s)", ErrLockOutOfSync, c.Name())
+	}
+
+	versions := make(map[string]string, len(c.Lock.Dependencies))
+	for _, dep := range c.Lock.Dependencies {
+		versions[dep.Name] = dep.Version
+	}
+
+	return versions, nil
+}
+
+// lockDigest computes the digest Helm records in Chart.lock from the
+// dependencies declared in Chart.yaml and the locked dependencies.
+func lockDigest(declared []*chart.Dependency, locked []*chart.Dependency) (string, error) {
+	data, err := json.Marshal([2][]*chart.Dependency{declared, locked})
+	if err != nil {
+		return "", fmt.Errorf("unable to digest dependencies: %w", err)
+	}
+
+	return godigest.FromBytes(data).String(), nil
+}
+
+// chartDir returns the directory a located chart was loaded from, or an
+// empty string for archives and charts that do not live on disk.
+func chartDir(result locator.Result) string {
+	if result.Path == "" || result.SourceType == locator.SourceFS || result.SourceType == locator.SourceLoaded {
+		return ""
+	}
+
+	info, err := os.Stat(result.Path)
+	if err != nil || !info.IsDir() {
+		return ""
+	}
+
+	return result.Path
+}
@@ -0,0 +1,285 @@
+package helm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	godigest "github.com/opencontainers/go-digest"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+
+	helm "github.com/k8s-manifest-kit/renderer-helm/pkg"
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+const dependencyIndexYAML = `apiVersion: v1
+entries:
+  sub:
+    - version: "1.1.0"
+      urls:
+        - sub-1.1.0.tgz
+    - version: "1.2.0"
+      urls:
+        - sub-1.2.0.tgz
+`
+
+func TestBuildDependencies(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should fetch dependencies that are not vendored", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+		chartDir := writeParentChart(t, "^1.0.0", srv.URL)
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:             chartDir,
+			ReleaseName:       "deps",
+			BuildDependencies: true,
+		}}, helm.WithRepositoryCache(t.TempDir()))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.2.0"))
+
+		results := renderer.Results()
+		g.Expect(results).To(HaveLen(1))
+		g.Expect(results[0].Dependencies).To(HaveLen(1))
+		g.Expect(results[0].Dependencies[0].Version).To(Equal("1.2.0"))
+	})
+
+	t.Run("should use versions pinned in Chart.lock", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+		chartDir := writeParentChart(t, "^1.0.0", srv.URL)
+		writeChartLock(t, chartDir, srv.URL, "^1.0.0", "1.1.0", "")
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:             chartDir,
+			ReleaseName:       "deps",
+			BuildDependencies: true,
+		}}, helm.WithRepositoryCache(t.TempDir()))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.1.0"))
+	})
+
+	t.Run("should reject a Chart.lock that is out of sync", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+		chartDir := writeParentChart(t, "^1.0.0", srv.URL)
+		writeChartLock(t, chartDir, srv.URL, "^1.0.0", "1.1.0", "sha256:0000")
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:             chartDir,
+			ReleaseName:       "deps",
+			BuildDependencies: true,
+		}}, helm.WithRepositoryCache(t.TempDir()))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, helm.ErrLockOutOfSync)).To(BeTrue())
+	})
+
+	t.Run("should not fetch vendored dependencies", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newDependencyServer(t)
+		chartDir := writeParentChart(t, "^1.0.0", srv.URL)
+		g.Expect(os.CopyFS(filepath.Join(chartDir, "charts"), subChart("1.0.0"))).To(Succeed())
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:             chartDir,
+			ReleaseName:       "deps",
+			BuildDependencies: true,
+		}}, helm.WithRepositoryCache(t.TempDir()))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.0.0"))
+		g.Expect(requests.Load()).To(BeZero())
+	})
+
+	t.Run("should load file dependencies relative to the chart", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		chartDir := writeParentChart(t, "", "file://../sub")
+		g.Expect(os.CopyFS(filepath.Dir(chartDir), subChart("1.0.0"))).To(Succeed())
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:             chartDir,
+			ReleaseName:       "deps",
+			BuildDependencies: true,
+		}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.0.0"))
+	})
+
+	t.Run("should use the TLS settings of the dependency host", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+
+		tlsSrv := httptest.NewTLSServer(srv.Config.Handler)
+		t.Cleanup(tlsSrv.Close)
+
+		config := filepath.Join(t.TempDir(), "repositories.yaml")
+		g.Expect(os.WriteFile(config, []byte(
+			"repositories:\n  - name: deps\n    url: "+tlsSrv.URL+"\n    insecure_skip_tls_verify: true\n",
+		), 0600)).To(Succeed())
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:             writeParentChart(t, "^1.0.0", tlsSrv.URL),
+			ReleaseName:       "deps",
+			BuildDependencies: true,
+		}},
+			helm.WithRepositoryCache(t.TempDir()),
+			helm.WithRepositoryConfig(config),
+			helm.WithTLSConfig(locator.TLSConfig{ServerName: "charts.example.com"}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.2.0"))
+	})
+
+	t.Run("should report dependencies without a repository", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		chartDir := writeParentChart(t, "1.0.0", "")
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:             chartDir,
+			ReleaseName:       "deps",
+			BuildDependencies: true,
+		}})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, helm.ErrDependencyNotVendored)).To(BeTrue())
+	})
+}
+
+// subChart returns an FS holding the "sub" chart at version, which renders
+// a ConfigMap recording its version.
+func subChart(version string) fstest.MapFS {
+	return fstest.MapFS{
+		"sub/Chart.yaml": {Data: []byte("apiVersion: v2\nname: sub\nversion: " + version + "\n")},
+		"sub/templates/configmap.yaml": {Data: []byte(
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sub\ndata:\n  version: {{ .Chart.Version | quote }}\n",
+		)},
+	}
+}
+
+// newDependencyServer serves a repository with versions 1.1.0 and 1.2.0 of
+// the "sub" chart and counts the requests it receives.
+func newDependencyServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+
+	archives := map[string][]byte{
+		"/sub-1.1.0.tgz": chartArchive(t, subChart("1.1.0")),
+		"/sub-1.2.0.tgz": chartArchive(t, subChart("1.2.0")),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.URL.Path == "/index.yaml" {
+			_, _ = w.Write([]byte(dependencyIndexYAML))
+
+			return
+		}
+
+		data, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+// writeParentChart writes a chart depending on "sub" at version from
+// repository and returns its directory.
+func writeParentChart(t *testing.T, version string, repository string) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "parent")
+
+	chartYAML := "apiVersion: v2\nname: parent\nversion: 1.0.0\ndependencies:\n" +
+		"  - name: sub\n    version: \"" + version + "\"\n    repository: \"" + repository + "\"\n"
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(chartYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// writeChartLock writes a Chart.lock pinning "sub" to locked. An empty
+// digest is replaced by the one Helm would record.
+func writeChartLock(t *testing.T, dir string, repository string, version string, locked string, digest string) {
+	t.Helper()
+
+	declared := []*chart.Dependency{{Name: "sub", Version: version, Repository: repository}}
+	lock := []*chart.Dependency{{Name: "sub", Version: locked, Repository: repository}}
+
+	if digest == "" {
+		data, err := json.Marshal([2][]*chart.Dependency{declared, lock})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		digest = godigest.FromBytes(data).String()
+	}
+
+	lockYAML := "dependencies:\n  - name: sub\n    repository: " + repository + "\n    version: " + locked + "\n" +
+		"digest: " + digest + "\ngenerated: \"2024-01-01T00:00:00Z\"\n"
+
+	if err := os.WriteFile(filepath.Join(dir, "Chart.lock"), []byte(lockYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,195 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	godigest "github.com/opencontainers/go-digest"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+)
+
+// fsArchiveFileMode is the mode of the files in archives built from an fs.FS.
+const fsArchiveFileMode = 0o644
+
+// providedChart returns the chart of a source that bypasses the locator,
+// either read from FS or supplied as Loaded. The result carries a digest of
+// the chart contents so that cache keys tell different charts apart.
+func (h *sourceHolder) providedChart(opts *RendererOptions) (*chart.Chart, locator.Result, error) {
+	if h.Verify || opts.Verify {
+		return nil, locator.Result{}, &LocateError{
+			Chart:   h.Chart,
+			Version: h.ReleaseVersion,
+			Err: fmt.Errorf(
+				"%w: charts read from FS or supplied as Loaded cannot be verified (name: %s)",
+				locator.ErrProvenanceNotFound,
+				h.Chart,
+			),
+		}
+	}
+
+	var (
+		c      *chart.Chart
+		result locator.Result
+		err    error
+	)
+
+	if h.Loaded != nil {
+		// Rendering modifies charts, so the caller's chart is never handed out
+		c = copyChart(h.Loaded)
+		result.SourceType = locator.SourceLoaded
+		result.Digest, err = loadedChartDigest(h.Loaded)
+	} else {
+		c, result.Digest, err = loadFSChart(h.FS, h.Chart)
+		result.SourceType = locator.SourceFS
+		result.Path = h.Chart
+	}
+
+	if err != nil {
+		return nil, locator.Result{}, &LocateError{
+			Chart:   h.Chart,
+			Version: h.ReleaseVersion,
+			Err:     fmt.Errorf("failed to load chart (name: %s): %w", h.Chart, err),
+		}
+	}
+
+	if c.Metadata != nil {
+		result.Version = c.Metadata.Version
+		result.Metadata = c.Metadata
+	}
+
+	return c, result, nil
+}
+
+// loadFSChart loads the chart directory or archive at name in fsys and
+// returns it with the digest of its archive.
+func loadFSChart(fsys fs.FS, name string) (*chart.Chart, string, error) {
+	root := path.Clean(strings.TrimPrefix(name, "/"))
+
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read chart from FS: %w", err)
+	}
+
+	var data []byte
+	if info.IsDir() {
+		data, err = archiveFSDir(fsys, root)
+	} else {
+		data, err = fs.ReadFile(fsys, root)
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read chart from FS: %w", err)
+	}
+
+	c, err := loader.LoadArchive(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to load chart archive: %w", err)
+	}
+
+	return c, godigest.FromBytes(data).String(), nil
+}
+
+// archiveFSDir packs the chart directory at root into a gzipped tarball in
+// the layout loader.LoadArchive expects. Headers carry no timestamps or
+// ownership, so the archive only depends on the chart files.
+func archiveFSDir(fsys fs.FS, root string) ([]byte, error) {
+	top := path.Base(root)
+	if top == "." {
+		top = "chart"
+	}
+
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return fmt.Errorf("unable to read %q: %w", p, err)
+		}
+
+		rel := p
+		if root != "." {
+			rel = strings.TrimPrefix(p, root+"/")
+		}
+
+		hdr := &tar.Header{
+			Name:     top + "/" + rel,
+			Typeflag: tar.TypeReg,
+			Mode:     fsArchiveFileMode,
+			Size:     int64(len(data)),
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("unable to archive %q: %w", p, err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("unable to archive %q: %w", p, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to archive chart directory %q: %w", root, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to archive chart directory %q: %w", root, err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to archive chart directory %q: %w", root, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// loadedChartDigest digests the contents of an already-loaded chart and its
+// dependencies.
+func loadedChartDigest(c *chart.Chart) (string, error) {
+	digester := godigest.Canonical.Digester()
+
+	var write func(c *chart.Chart) error
+
+	write = func(c *chart.Chart) error {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return fmt.Errorf("unable to digest chart %q: %w", c.Name(), err)
+		}
+
+		_, _ = digester.Hash().Write(data)
+
+		for _, dep := range c.Dependencies() {
+			if err := write(dep); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := write(c); err != nil {
+		return "", err
+	}
+
+	return digester.Digest().String(), nil
+}
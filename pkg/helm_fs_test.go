@@ -0,0 +1,248 @@
+package helm_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+
+	"github.com/k8s-manifest-kit/engine/pkg/types"
+	helm "github.com/k8s-manifest-kit/renderer-helm/pkg"
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestProvidedCharts(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should render charts from an fs.FS", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		renderer, err := helm.New([]helm.Source{
+			{
+				FS:          os.DirFS("../config/test/charts"),
+				Chart:       "simple-app",
+				ReleaseName: "fs-test",
+			},
+		}, helm.WithSourceAnnotations(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(3))
+
+		for _, obj := range objects {
+			g.Expect(obj.GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourcePath, "simple-app"))
+		}
+
+		results := renderer.Results()
+		g.Expect(results).To(HaveLen(1))
+		g.Expect(results[0].Result.SourceType).To(Equal(locator.SourceFS))
+		g.Expect(results[0].Result.Path).To(Equal("simple-app"))
+		g.Expect(results[0].Result.Version).To(Equal("1.0.0"))
+		g.Expect(results[0].Result.Digest).To(HavePrefix("sha256:"))
+	})
+
+	t.Run("should render chart archives from an fs.FS", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		fsys := fstest.MapFS{
+			"charts/simple-app-1.0.0.tgz": {Data: chartArchive(t, os.DirFS("../config/test/charts"))},
+		}
+
+		renderer, err := helm.New([]helm.Source{
+			{
+				FS:          fsys,
+				Chart:       "charts/simple-app-1.0.0.tgz",
+				ReleaseName: "fs-archive-test",
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(3))
+	})
+
+	t.Run("should render loaded charts", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		c, err := loader.Load(testChartPath)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := helm.New([]helm.Source{
+			{
+				Loaded:      c,
+				ReleaseName: "loaded-test",
+			},
+		}, helm.WithSourceAnnotations(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(3))
+
+		for _, obj := range objects {
+			g.Expect(obj.GetAnnotations()).To(HaveKeyWithValue(types.AnnotationSourcePath, "simple-app"))
+		}
+
+		results := renderer.Results()
+		g.Expect(results).To(HaveLen(1))
+		g.Expect(results[0].Source.Chart).To(Equal("simple-app"))
+		g.Expect(results[0].Result.SourceType).To(Equal(locator.SourceLoaded))
+		g.Expect(results[0].Result.Digest).To(HavePrefix("sha256:"))
+	})
+
+	t.Run("should not modify loaded charts", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.CopyFS(dir, fstest.MapFS{
+			"parent/Chart.yaml": {Data: []byte("apiVersion: v2\nname: parent\nversion: 1.0.0\ndependencies:\n" +
+				"  - name: sub\n    version: \"1.0.0\"\n    condition: sub.enabled\n")},
+			"parent/values.yaml": {Data: []byte("sub:\n  enabled: false\n")},
+		})).To(Succeed())
+		g.Expect(os.CopyFS(filepath.Join(dir, "parent", "charts"), subChart("1.0.0"))).To(Succeed())
+
+		c, err := loader.Load(filepath.Join(dir, "parent"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		original, err := loader.Load(filepath.Join(dir, "parent"))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := helm.New([]helm.Source{
+			{
+				Loaded:              c,
+				ReleaseName:         "enabled",
+				ProcessDependencies: true,
+				Values:              helm.Values(map[string]any{"sub": map[string]any{"enabled": true}}),
+			},
+			{
+				Loaded:              c,
+				ReleaseName:         "disabled",
+				ProcessDependencies: true,
+			},
+		}, helm.WithConcurrency(2))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		for range 2 {
+			objects, err := renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(objects).To(HaveLen(1))
+		}
+
+		g.Expect(c.Dependencies()).To(HaveLen(1))
+		g.Expect(c.Values).To(Equal(original.Values))
+		g.Expect(c.Metadata).To(Equal(original.Metadata))
+	})
+
+	t.Run("should not share cache entries between charts of the same name", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		configMapChart := func(value string) fstest.MapFS {
+			return fstest.MapFS{
+				"chart/Chart.yaml": {Data: []byte("apiVersion: v2\nname: config\nversion: 1.0.0\n")},
+				"chart/templates/configmap.yaml": {Data: []byte(
+					"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  value: " + value + "\n",
+				)},
+			}
+		}
+
+		renderer, err := helm.New([]helm.Source{
+			{FS: configMapChart("first"), Chart: "chart", ReleaseName: "config"},
+			{FS: configMapChart("second"), Chart: "chart", ReleaseName: "config"},
+		}, helm.WithCache())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("value", "first"))
+		g.Expect(objects[1].Object["data"]).To(HaveKeyWithValue("value", "second"))
+	})
+
+	t.Run("should reject conflicting sources", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		c, err := loader.Load(testChartPath)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = helm.New([]helm.Source{
+			{FS: os.DirFS(testChartPath), Loaded: c, Chart: ".", ReleaseName: "conflict"},
+		})
+		g.Expect(helm.IsValidationError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, helm.ErrSourceConflict)).To(BeTrue())
+
+		_, err = helm.New([]helm.Source{
+			{Repo: "https://charts.example.com", Loaded: c, ReleaseName: "conflict"},
+		})
+		g.Expect(errors.Is(err, helm.ErrSourceConflict)).To(BeTrue())
+	})
+
+	t.Run("should refuse verification", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		renderer, err := helm.New([]helm.Source{
+			{FS: os.DirFS(testChartPath), Chart: ".", ReleaseName: "verify", Verify: true},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, locator.ErrProvenanceNotFound)).To(BeTrue())
+	})
+
+	t.Run("should report missing charts", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		renderer, err := helm.New([]helm.Source{
+			{FS: fstest.MapFS{}, Chart: "missing", ReleaseName: "missing"},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, fs.ErrNotExist)).To(BeTrue())
+	})
+}
+
+// chartArchive packs fsys, which holds a single chart directory, into a
+// gzipped tarball.
+func chartArchive(t *testing.T, fsys fs.FS) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+
+	if err := tw.AddFS(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,352 @@
+package helm
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/container"
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/internal/fsutil"
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+)
+
+// lockfileAPIVersion is the format version written to lockfiles.
+const lockfileAPIVersion = "v1"
+
+var (
+	// ErrLockMissing is returned in frozen mode when a source is not pinned by
+	// the lockfile.
+	ErrLockMissing = errors.New("source is not pinned by the lockfile")
+
+	// ErrLockStale is returned when a lockfile entry no longer matches: the
+	// locked archive digest differs from the one located, or, in frozen mode,
+	// the source's ReleaseVersion changed since the entry was recorded.
+	ErrLockStale = errors.New("lockfile entry is stale")
+
+	// ErrLockfileNotSet is returned when frozen mode or a lock update is
+	// requested without a lockfile.
+	ErrLockfileNotSet = errors.New("lockfile path is not set")
+)
+
+// Lockfile records the chart each source resolved to, so that later runs
+// render the same chart versions and archives.
+type Lockfile struct {
+	APIVersion string         `json:"apiVersion"`
+	Sources    []LockedSource `json:"sources"`
+}
+
+// LockedSource pins the chart of a source. Sources are matched by Repo,
+// Chart and Constraint, so sources sharing a chart share an entry.
+type LockedSource struct {
+	Repo  string `json:"repo,omitempty"`
+	Chart string `json:"chart"`
+
+	// Constraint is the ReleaseVersion the chart was resolved for.
+	Constraint string `json:"constraint,omitempty"`
+
+	// SourceType tells how the chart was located.
+	SourceType locator.SourceType `json:"sourceType"`
+
+	// Version is the chart version the constraint resolved to.
+	Version string `json:"version"`
+
+	// Digest is the archive digest the located chart must match. It is empty
+	// for git charts, which are pinned to the commit in URL instead.
+	Digest string `json:"digest,omitempty"`
+
+	// URL is where the chart was fetched from, see locator.Result.URL.
+	URL string `json:"url,omitempty"`
+}
+
+// lockState is the lockfile shared by the sources of a Renderer.
+type lockState struct {
+	mu     sync.Mutex
+	path   string
+	frozen bool
+	file   Lockfile
+}
+
+// readLockfile reads the lockfile at path. A missing lockfile is empty.
+func readLockfile(path string, frozen bool) (*lockState, error) {
+	state := &lockState{
+		path:   path,
+		frozen: frozen,
+		file:   Lockfile{APIVersion: lockfileAPIVersion},
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // caller controls path
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read lockfile %q: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, &state.file); err != nil {
+		return nil, fmt.Errorf("unable to parse lockfile %q: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// Lockfile returns the current contents of the lockfile, including entries
+// recorded by this Renderer. It is empty without WithLockfile.
+// This method is safe for concurrent use.
+func (r *Renderer) Lockfile() Lockfile {
+	if r.lock == nil {
+		return Lockfile{}
+	}
+
+	r.lock.mu.Lock()
+	defer r.lock.mu.Unlock()
+
+	return Lockfile{
+		APIVersion: r.lock.file.APIVersion,
+		Sources:    slices.Clone(r.lock.file.Sources),
+	}
+}
+
+// UpdateLock resolves every source again within its ReleaseVersion
+// constraint, ignoring the current pins, and rewrites the lockfile with the
+// results. Entries no source refers to any more are dropped. Charts already
+// loaded by this Renderer are not reloaded; call Renderer.Refresh to render
+// the updated pins.
+func (r *Renderer) UpdateLock(ctx context.Context) error {
+	if r.lock == nil {
+		return &ValidationError{Field: "Lockfile", Err: ErrLockfileNotSet}
+	}
+
+	entries := make([]LockedSource, 0, len(r.inputs))
+
+	for _, holder := range r.inputs {
+		if holder.FS != nil || holder.Loaded != nil {
+			continue
+		}
+
+		result, err := locator.Locate(ctx, holder.locatorRequest(&r.opts))
+		if err != nil {
+			return &LocateError{
+				Chart:   holder.Chart,
+				Repo:    holder.Repo,
+				Version: holder.ReleaseVersion,
+				Err: fmt.Errorf(
+					"unable to locate chart (repo: %s, name: %s, version: %s): %w",
+					holder.Repo,
+					holder.Chart,
+					holder.ReleaseVersion,
+					err,
+				),
+			}
+		}
+
+		if lockable(result) {
+			entries = upsertLocked(entries, lockedSource(holder, result))
+		}
+	}
+
+	r.lock.mu.Lock()
+	defer r.lock.mu.Unlock()
+
+	r.lock.file = Lockfile{APIVersion: lockfileAPIVersion, Sources: entries}
+
+	return r.lock.write()
+}
+
+// lookup returns the entry pinning h, if any.
+func (l *lockState) lookup(h *sourceHolder) *LockedSource {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := l.index(h.Repo, h.Chart, h.ReleaseVersion)
+	if i < 0 {
+		return nil
+	}
+
+	entry := l.file.Sources[i]
+
+	return &entry
+}
+
+// pinRequest narrows a locator request to the chart locked by entry. OCI
+// refs that carry a tag or digest are pinned by the ref already, and OCI
+// charts must match the locked layer digest before they are downloaded.
+func pinRequest(req *locator.Request, entry *LockedSource) {
+	if entry == nil {
+		return
+	}
+
+	switch {
+	case entry.SourceType == locator.SourceGit && entry.URL != "":
+		req.Version = entry.Version
+		req.Name = entry.URL
+	case entry.SourceType == locator.SourceOCI:
+		if container.EmbeddedTag(req.Name) == "" && container.EmbeddedDigest(req.Name) == "" {
+			req.Version = entry.Version
+		}
+
+		req.Digest = entry.Digest
+	default:
+		req.Version = entry.Version
+	}
+}
+
+// settle checks the chart located for h against its entry, or records it
+// when h was not pinned yet. In frozen mode unpinned sources fail instead.
+func (l *lockState) settle(h *sourceHolder, entry *LockedSource, result locator.Result) error {
+	if l == nil || !lockable(result) {
+		return nil
+	}
+
+	if entry != nil {
+		if entry.Digest != "" && entry.Digest != result.Digest {
+			return lockError(h, fmt.Errorf(
+				"%w: chart %s %s has digest %s, locked %s",
+				ErrLockStale,
+				h.Chart,
+				entry.Version,
+				result.Digest,
+				entry.Digest,
+			))
+		}
+
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.frozen {
+		return l.unpinned(h)
+	}
+
+	l.file.Sources = upsertLocked(l.file.Sources, lockedSource(h, result))
+
+	return l.write()
+}
+
+// require fails in frozen mode when h has no entry, before its chart is
+// located, so that unpinned sources never reach the network. Local charts
+// are never pinned and pass.
+func (l *lockState) require(h *sourceHolder, entry *LockedSource) error {
+	if l == nil || !l.frozen || entry != nil || localChart(h) {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.unpinned(h)
+}
+
+// unpinned returns the error of frozen renders of h, which has no entry.
+// The caller must hold mu.
+func (l *lockState) unpinned(h *sourceHolder) error {
+	if slices.ContainsFunc(l.file.Sources, func(e LockedSource) bool {
+		return e.Repo == h.Repo && e.Chart == h.Chart
+	}) {
+		return lockError(h, fmt.Errorf(
+			"%w: chart %s is locked for another version constraint than %q",
+			ErrLockStale,
+			h.Chart,
+			h.ReleaseVersion,
+		))
+	}
+
+	return lockError(h, fmt.Errorf("%w: chart %s", ErrLockMissing, h.Chart))
+}
+
+// index returns the position of the entry for repo, chart and constraint,
+// or -1. The caller must hold mu.
+func (l *lockState) index(repo string, chart string, constraint string) int {
+	return slices.IndexFunc(l.file.Sources, func(e LockedSource) bool {
+		return e.Repo == repo && e.Chart == chart && e.Constraint == constraint
+	})
+}
+
+// write saves the lockfile atomically. The caller must hold mu.
+func (l *lockState) write() error {
+	data, err := yaml.Marshal(l.file)
+	if err != nil {
+		return fmt.Errorf("unable to encode lockfile: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(l.path, data); err != nil {
+		return fmt.Errorf("unable to write lockfile %q: %w", l.path, err)
+	}
+
+	return nil
+}
+
+// lockable reports whether a located chart can be pinned. Local charts are
+// pinned by the filesystem already.
+func lockable(result locator.Result) bool {
+	switch result.SourceType {
+	case locator.SourceLocal, locator.SourceFS, locator.SourceLoaded:
+		return false
+	default:
+		return true
+	}
+}
+
+// localChart reports whether the locator reads the chart of h from the local
+// filesystem, the way locator.Locate decides it.
+func localChart(h *sourceHolder) bool {
+	if h.Repo != "" {
+		return false
+	}
+
+	name := strings.TrimSpace(h.Chart)
+	if _, err := os.Stat(name); err == nil {
+		return true
+	}
+
+	return filepath.IsAbs(name) || strings.HasPrefix(name, ".")
+}
+
+func lockedSource(h *sourceHolder, result locator.Result) LockedSource {
+	return LockedSource{
+		Repo:       h.Repo,
+		Chart:      h.Chart,
+		Constraint: h.ReleaseVersion,
+		SourceType: result.SourceType,
+		Version:    result.Version,
+		Digest:     result.Digest,
+		URL:        result.URL,
+	}
+}
+
+// upsertLocked replaces the entry matching e or adds it, keeping entries
+// sorted so that the lockfile diffs cleanly.
+func upsertLocked(entries []LockedSource, e LockedSource) []LockedSource {
+	entries = slices.DeleteFunc(entries, func(x LockedSource) bool {
+		return x.Repo == e.Repo && x.Chart == e.Chart && x.Constraint == e.Constraint
+	})
+	entries = append(entries, e)
+
+	slices.SortFunc(entries, func(a LockedSource, b LockedSource) int {
+		return cmp.Or(
+			cmp.Compare(a.Chart, b.Chart),
+			cmp.Compare(a.Repo, b.Repo),
+			cmp.Compare(a.Constraint, b.Constraint),
+		)
+	})
+
+	return entries
+}
+
+func lockError(h *sourceHolder, err error) *LockError {
+	return &LockError{Chart: h.Chart, Repo: h.Repo, Version: h.ReleaseVersion, Err: err}
+}
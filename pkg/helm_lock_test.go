@@ -0,0 +1,393 @@
+package helm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	godigest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"sigs.k8s.io/yaml"
+
+	helm "github.com/k8s-manifest-kit/renderer-helm/pkg"
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLockfile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should record resolved versions and digests", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+		lockPath := filepath.Join(t.TempDir(), "helm.lock")
+
+		renderer, err := helm.New([]helm.Source{{
+			Repo:           srv.URL,
+			Chart:          "sub",
+			ReleaseName:    "locked",
+			ReleaseVersion: "^1.0.0",
+		}}, helm.WithRepositoryCache(t.TempDir()), helm.WithLockfile(lockPath))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		lock := readLockfile(t, lockPath)
+		g.Expect(lock.Sources).To(HaveLen(1))
+		g.Expect(lock.Sources[0]).To(Equal(helm.LockedSource{
+			Repo:       srv.URL,
+			Chart:      "sub",
+			Constraint: "^1.0.0",
+			SourceType: locator.SourceRepo,
+			Version:    "1.2.0",
+			Digest:     subChartDigest(t, "1.2.0"),
+			URL:        srv.URL + "/sub-1.2.0.tgz",
+		}))
+		g.Expect(renderer.Lockfile()).To(Equal(lock))
+	})
+
+	t.Run("should render the locked version", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+		lockPath := writeLockfile(t, helm.LockedSource{
+			Repo:       srv.URL,
+			Chart:      "sub",
+			Constraint: "^1.0.0",
+			SourceType: locator.SourceRepo,
+			Version:    "1.1.0",
+			Digest:     subChartDigest(t, "1.1.0"),
+		})
+
+		renderer, err := helm.New([]helm.Source{{
+			Repo:           srv.URL,
+			Chart:          "sub",
+			ReleaseName:    "locked",
+			ReleaseVersion: "^1.0.0",
+		}}, helm.WithRepositoryCache(t.TempDir()), helm.WithLockfile(lockPath), helm.WithFrozenLockfile(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.1.0"))
+	})
+
+	t.Run("should refuse archives that do not match the locked digest", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+		lockPath := writeLockfile(t, helm.LockedSource{
+			Repo:       srv.URL,
+			Chart:      "sub",
+			Constraint: "^1.0.0",
+			SourceType: locator.SourceRepo,
+			Version:    "1.1.0",
+			Digest:     subChartDigest(t, "1.2.0"),
+		})
+
+		renderer, err := helm.New([]helm.Source{{
+			Repo:           srv.URL,
+			Chart:          "sub",
+			ReleaseName:    "locked",
+			ReleaseVersion: "^1.0.0",
+		}}, helm.WithRepositoryCache(t.TempDir()), helm.WithLockfile(lockPath))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLockError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, helm.ErrLockStale)).To(BeTrue())
+	})
+
+	t.Run("should fail frozen renders of unpinned sources", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newDependencyServer(t)
+		lockPath := writeLockfile(t, helm.LockedSource{
+			Repo:       srv.URL,
+			Chart:      "sub",
+			Constraint: "~1.1.0",
+			SourceType: locator.SourceRepo,
+			Version:    "1.1.0",
+			Digest:     subChartDigest(t, "1.1.0"),
+		})
+
+		missingPath := filepath.Join(t.TempDir(), "missing.lock")
+
+		for _, tc := range []struct {
+			lockPath string
+			expected error
+		}{
+			{lockPath: missingPath, expected: helm.ErrLockMissing},
+			{lockPath: lockPath, expected: helm.ErrLockStale},
+		} {
+			renderer, err := helm.New([]helm.Source{{
+				Repo:           srv.URL,
+				Chart:          "sub",
+				ReleaseName:    "locked",
+				ReleaseVersion: "^1.0.0",
+			}}, helm.WithRepositoryCache(t.TempDir()), helm.WithLockfile(tc.lockPath), helm.WithFrozenLockfile(true))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, err = renderer.Process(t.Context(), nil)
+			g.Expect(helm.IsLockError(err)).To(BeTrue())
+			g.Expect(errors.Is(err, tc.expected)).To(BeTrue())
+		}
+
+		g.Expect(missingPath).ToNot(BeAnExistingFile())
+		g.Expect(requests.Load()).To(BeZero())
+	})
+
+	t.Run("should not pin local charts", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		lockPath := filepath.Join(t.TempDir(), "helm.lock")
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:       testChartPath,
+			ReleaseName: "local",
+		}}, helm.WithLockfile(lockPath), helm.WithFrozenLockfile(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(lockPath).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("should update pins within the constraints", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+		lockPath := writeLockfile(t,
+			helm.LockedSource{
+				Repo:       srv.URL,
+				Chart:      "sub",
+				Constraint: "^1.0.0",
+				SourceType: locator.SourceRepo,
+				Version:    "1.1.0",
+				Digest:     subChartDigest(t, "1.1.0"),
+			},
+			helm.LockedSource{
+				Repo:       srv.URL,
+				Chart:      "removed",
+				SourceType: locator.SourceRepo,
+				Version:    "1.0.0",
+			},
+		)
+
+		renderer, err := helm.New([]helm.Source{{
+			Repo:           srv.URL,
+			Chart:          "sub",
+			ReleaseName:    "locked",
+			ReleaseVersion: "^1.0.0",
+		}}, helm.WithRepositoryCache(t.TempDir()), helm.WithLockfile(lockPath))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(renderer.UpdateLock(t.Context())).To(Succeed())
+
+		lock := readLockfile(t, lockPath)
+		g.Expect(lock.Sources).To(HaveLen(1))
+		g.Expect(lock.Sources[0].Version).To(Equal("1.2.0"))
+		g.Expect(lock.Sources[0].Digest).To(Equal(subChartDigest(t, "1.2.0")))
+	})
+
+	t.Run("should render tagged OCI refs again from the lockfile", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newOCIChartServer(t, chartArchive(t, subChart("1.1.0")))
+		lockPath := filepath.Join(t.TempDir(), "helm.lock")
+		cacheDir := t.TempDir()
+
+		for _, frozen := range []bool{false, true, true} {
+			renderer, err := helm.New([]helm.Source{{
+				Chart:       "oci://" + srv.ref + ":1.1.0",
+				ReleaseName: "tagged",
+			}}, helm.WithRepositoryCache(cacheDir), helm.WithLockfile(lockPath), helm.WithFrozenLockfile(frozen))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			objects, err := renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(objects).To(HaveLen(1))
+			g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.1.0"))
+		}
+
+		lock := readLockfile(t, lockPath)
+		g.Expect(lock.Sources).To(HaveLen(1))
+		g.Expect(lock.Sources[0].SourceType).To(Equal(locator.SourceOCI))
+		g.Expect(lock.Sources[0].Version).To(Equal("1.1.0"))
+		g.Expect(lock.Sources[0].Digest).To(Equal(subChartDigest(t, "1.1.0")))
+	})
+
+	t.Run("should refuse re-pushed OCI tags before downloading them", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newOCIChartServer(t, chartArchive(t, subChart("1.2.0")))
+		lockPath := writeLockfile(t, helm.LockedSource{
+			Chart:      "oci://" + srv.ref + ":1.1.0",
+			SourceType: locator.SourceOCI,
+			Version:    "1.1.0",
+			Digest:     subChartDigest(t, "1.1.0"),
+		})
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:       "oci://" + srv.ref + ":1.1.0",
+			ReleaseName: "repushed",
+		}}, helm.WithRepositoryCache(t.TempDir()), helm.WithLockfile(lockPath), helm.WithFrozenLockfile(true))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLockError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, helm.ErrLockStale)).To(BeTrue())
+		g.Expect(locator.IsDigestMismatchError(err)).To(BeTrue())
+		g.Expect(srv.blobFetches.Load()).To(BeZero())
+	})
+
+	t.Run("should require a lockfile", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := helm.New([]helm.Source{{Chart: testChartPath, ReleaseName: "local"}}, helm.WithFrozenLockfile(true))
+		g.Expect(helm.IsValidationError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, helm.ErrLockfileNotSet)).To(BeTrue())
+
+		renderer, err := helm.New([]helm.Source{{Chart: testChartPath, ReleaseName: "local"}})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(errors.Is(renderer.UpdateLock(t.Context()), helm.ErrLockfileNotSet)).To(BeTrue())
+	})
+}
+
+// subChartDigest returns the digest of the "sub" chart archive at version
+// served by newDependencyServer.
+func subChartDigest(t *testing.T, version string) string {
+	t.Helper()
+
+	return godigest.FromBytes(chartArchive(t, subChart(version))).String()
+}
+
+func writeLockfile(t *testing.T, sources ...helm.LockedSource) string {
+	t.Helper()
+
+	data, err := yaml.Marshal(helm.Lockfile{APIVersion: "v1", Sources: sources})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "helm.lock")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func readLockfile(t *testing.T, path string) helm.Lockfile {
+	t.Helper()
+
+	data, err := os.ReadFile(path) //nolint:gosec // test-controlled path
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lock helm.Lockfile
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		t.Fatal(err)
+	}
+
+	return lock
+}
+
+// ociChartServer is a registry serving a single chart archive under every tag
+// of its repository.
+type ociChartServer struct {
+	ref         string
+	blobFetches *atomic.Int32
+}
+
+func newOCIChartServer(t *testing.T, archive []byte) *ociChartServer {
+	t.Helper()
+
+	const repo = "test/sub"
+
+	config := []byte(`{"name":"sub"}`)
+	layer := godigest.FromBytes(archive)
+
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config: ocispec.Descriptor{
+			MediaType: "application/vnd.cncf.helm.config.v1+json",
+			Digest:    godigest.FromBytes(config),
+			Size:      int64(len(config)),
+		},
+		Layers: []ocispec.Descriptor{{
+			MediaType: "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+			Digest:    layer,
+			Size:      int64(len(archive)),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobs := map[string][]byte{
+		layer.String():                      archive,
+		godigest.FromBytes(config).String(): config,
+	}
+
+	var blobFetches atomic.Int32
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v2/"+repo+"/manifests/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", godigest.FromBytes(manifest).String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(manifest)))
+		_, _ = w.Write(manifest)
+	})
+
+	mux.HandleFunc("/v2/"+repo+"/blobs/", func(w http.ResponseWriter, r *http.Request) {
+		d := strings.TrimPrefix(r.URL.Path, "/v2/"+repo+"/blobs/")
+
+		data, ok := blobs[d]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		if d == layer.String() {
+			blobFetches.Add(1)
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Docker-Content-Digest", d)
+		_, _ = w.Write(data)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return &ociChartServer{
+		ref:         srv.Listener.Addr().String() + "/" + repo,
+		blobFetches: &blobFetches,
+	}
+}
@@ -1,9 +1,14 @@
 package helm
 
 import (
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/k8s-manifest-kit/engine/pkg/types"
 	"github.com/k8s-manifest-kit/pkg/util"
 	"github.com/k8s-manifest-kit/pkg/util/cache"
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
 )
 
 // RendererOption is a generic option for RendererOptions.
@@ -23,12 +28,82 @@ type RendererOptions struct {
 	// SourceSelectors are renderer-specific source selectors evaluated before rendering each source.
 	SourceSelectors []SourceSelector
 
-	// RepositoryConfig is the path to the repositories file.
+	// RepositoryConfig is the path to the repositories file. It is used to resolve
+	// "repo/chart" names and per-repository credentials and TLS settings.
 	RepositoryConfig string
 
 	// RepositoryCache is the path to the repository cache directory.
 	RepositoryCache string
 
+	// CredentialProviders are asked in order for credentials by host for
+	// sources without their own Credentials.
+	CredentialProviders []locator.CredentialProvider
+
+	// Mirrors rewrite repository index and archive URLs. The first mirror
+	// whose prefix matches a URL is applied.
+	Mirrors []locator.Mirror
+
+	// TLS configures TLS for repository and registry connections of every
+	// source without its own Source.TLS. It takes precedence over the TLS
+	// settings of the repositories file.
+	TLS *locator.TLSConfig
+
+	// HTTPClient is used for every repository and registry request. It
+	// replaces the TLS settings, which can be configured on the client itself.
+	HTTPClient *http.Client
+
+	// Transport is used to build the HTTP client when HTTPClient is nil.
+	Transport http.RoundTripper
+
+	// Registries holds per-host plain HTTP and insecure settings for OCI
+	// registries.
+	Registries []locator.Registry
+
+	// Locators build the locator for chart references with a custom scheme,
+	// keyed by scheme.
+	Locators map[string]locator.LocatorFactory
+
+	// Getters fetch repository indexes and archives for URL schemes other
+	// than http and https, keyed by scheme.
+	Getters map[string]locator.Getter
+
+	// Retry retries transient download failures of every source. nil =
+	// no retries.
+	Retry *locator.RetryPolicy
+
+	// RequireDigest refuses repository index entries that carry no digest.
+	// Digests that are present are always verified against the download.
+	RequireDigest bool
+
+	// Offline restricts chart resolution to the repository cache. Charts and
+	// versions that were never downloaded fail with a locator.NotCachedError.
+	Offline bool
+
+	// Lockfile is the path of the lockfile pinning the chart version and
+	// archive digest each source resolved to. Empty = no lockfile.
+	Lockfile string
+
+	// FrozenLockfile renders only what the lockfile pins: sources missing from
+	// it or no longer matching it fail with a *LockError instead of being
+	// resolved and recorded. Requires Lockfile.
+	FrozenLockfile bool
+
+	// RefreshInterval re-resolves the chart of every source once it has been
+	// loaded for this long. Source.RefreshInterval overrides it. 0 = charts
+	// are loaded once, see Renderer.Refresh.
+	RefreshInterval time.Duration
+
+	// Concurrency is the number of sources located and rendered in parallel
+	// by Process. 0 or 1 = one source at a time.
+	Concurrency int
+
+	// Verify enables provenance verification for every source.
+	Verify bool
+
+	// Keyring is the path to the OpenPGP public keyring used to verify chart
+	// provenance. Default: $GNUPGHOME/pubring.gpg or ~/.gnupg/pubring.gpg.
+	Keyring string
+
 	// ContentCache is the path to the content-addressable cache directory.
 	ContentCache string
 
@@ -66,10 +141,55 @@ func (opts RendererOptions) ApplyTo(target *RendererOptions) {
 		target.RepositoryCache = opts.RepositoryCache
 	}
 
+	target.CredentialProviders = opts.CredentialProviders
+	target.Mirrors = opts.Mirrors
+
+	if opts.TLS != nil {
+		target.TLS = opts.TLS
+	}
+
+	if opts.HTTPClient != nil {
+		target.HTTPClient = opts.HTTPClient
+	}
+
+	if opts.Transport != nil {
+		target.Transport = opts.Transport
+	}
+
+	target.Registries = opts.Registries
+	target.Locators = opts.Locators
+	target.Getters = opts.Getters
+
+	if opts.Retry != nil {
+		target.Retry = opts.Retry
+	}
+
+	if opts.Concurrency != 0 {
+		target.Concurrency = opts.Concurrency
+	}
+
 	if opts.ContentCache != "" {
 		target.ContentCache = opts.ContentCache
 	}
 
+	if opts.Keyring != "" {
+		target.Keyring = opts.Keyring
+	}
+
+	target.Verify = opts.Verify
+	target.RequireDigest = opts.RequireDigest
+	target.Offline = opts.Offline
+
+	if opts.Lockfile != "" {
+		target.Lockfile = opts.Lockfile
+	}
+
+	target.FrozenLockfile = opts.FrozenLockfile
+
+	if opts.RefreshInterval != 0 {
+		target.RefreshInterval = opts.RefreshInterval
+	}
+
 	if opts.CacheOptions != nil {
 		if target.CacheOptions == nil {
 			target.CacheOptions = &cache.Options{}
@@ -128,6 +248,171 @@ func WithRepositoryCache(path string) RendererOption {
 	})
 }
 
+// WithCredentialProvider adds a provider of credentials by host, such as
+// locator.NetrcProvider, locator.DockerConfigProvider or
+// locator.HelmRegistryConfigProvider(). Providers are asked in the order they
+// were added, for sources without their own Credentials and repositories
+// without credentials in the repositories file.
+func WithCredentialProvider(p locator.CredentialProvider) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.CredentialProviders = append(opts.CredentialProviders, p)
+	})
+}
+
+// WithVerification enables provenance verification for every source, using
+// the OpenPGP public keyring at keyringPath. An empty path keeps the default
+// keyring. Charts without a valid signature fail to load.
+func WithVerification(keyringPath string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Verify = true
+		if keyringPath != "" {
+			opts.Keyring = keyringPath
+		}
+	})
+}
+
+// WithRequireDigest enables or disables refusing repository index entries
+// that carry no digest.
+func WithRequireDigest(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.RequireDigest = enabled
+	})
+}
+
+// WithOffline enables or disables offline mode. In offline mode charts are
+// resolved from the repository cache only and no network access is made.
+func WithOffline(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Offline = enabled
+	})
+}
+
+// WithLockfile pins sources to the lockfile at path. Sources found in it are
+// rendered at the locked chart version and must match the locked archive
+// digest; other sources are resolved as usual and added to it. See
+// Renderer.UpdateLock to move the pins within the sources' constraints.
+func WithLockfile(path string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Lockfile = path
+	})
+}
+
+// WithFrozenLockfile enables or disables frozen mode, in which every source
+// must already be pinned by the lockfile set with WithLockfile.
+func WithFrozenLockfile(enabled bool) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.FrozenLockfile = enabled
+	})
+}
+
+// WithRefreshInterval re-resolves the chart of every source once it has been
+// loaded for interval, picking up new versions matching the source's
+// ReleaseVersion. Render cache entries of replaced charts are no longer hit.
+// A failed refresh keeps the loaded chart and is retried after interval.
+func WithRefreshInterval(interval time.Duration) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.RefreshInterval = interval
+	})
+}
+
+// WithConcurrency locates and renders up to n sources in parallel. Objects
+// are still returned in source order, so output and content hashes do not
+// depend on n. The first failing source cancels the others.
+func WithConcurrency(n int) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Concurrency = n
+	})
+}
+
+// WithTLSConfig sets the TLS configuration (CA bundle, client certificate and
+// key, server name, skip-verify) used for repository and registry connections.
+// A Source with its own TLS configuration uses that instead.
+func WithTLSConfig(cfg locator.TLSConfig) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.TLS = &cfg
+	})
+}
+
+// WithHTTPClient sets the HTTP client used for all repository and registry
+// requests, e.g. to route through a proxy or add request signing. Registry
+// authentication is layered on top of it. It replaces WithTLSConfig and the
+// TLS settings of sources and the repositories file.
+func WithHTTPClient(client *http.Client) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.HTTPClient = client
+	})
+}
+
+// WithTransport sets the transport used for all repository and registry
+// requests. It is ignored when WithHTTPClient is also set, and like it
+// replaces the TLS settings.
+func WithTransport(transport http.RoundTripper) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Transport = transport
+	})
+}
+
+// WithPlainHTTPRegistry talks to OCI registries whose host matches host over
+// plain HTTP. host is a registry host such as "registry.local:5000" or a
+// path.Match pattern such as "*.internal"; see locator.Registry.
+func WithPlainHTTPRegistry(host string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Registries = append(opts.Registries, locator.Registry{Host: host, PlainHTTP: true})
+	})
+}
+
+// WithInsecureRegistry accepts any certificate from OCI registries whose host
+// matches host, given as for WithPlainHTTPRegistry.
+func WithInsecureRegistry(host string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Registries = append(opts.Registries, locator.Registry{Host: host, Insecure: true})
+	})
+}
+
+// WithLocator registers factory to locate charts whose Source.Repo, or
+// Source.Chart when Repo is empty, has the given scheme, e.g. "s3". It takes
+// precedence over the built-in handling of "oci", "http" and "https".
+func WithLocator(scheme string, factory locator.LocatorFactory) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		if opts.Locators == nil {
+			opts.Locators = map[string]locator.LocatorFactory{}
+		}
+
+		opts.Locators[strings.ToLower(scheme)] = factory
+	})
+}
+
+// WithGetter registers getter to fetch repository indexes, chart archives and
+// provenance files whose URLs have the given scheme. Use
+// locator.HelmPluginGetters to register the downloader plugins of the Helm CLI.
+func WithGetter(scheme string, getter locator.Getter) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		if opts.Getters == nil {
+			opts.Getters = map[string]locator.Getter{}
+		}
+
+		opts.Getters[strings.ToLower(scheme)] = getter
+	})
+}
+
+// WithRetry retries downloads that fail with a transient error, such as a 503
+// from a repository or a reset connection, according to policy. Start from
+// locator.DefaultRetryPolicy to override single settings.
+func WithRetry(policy locator.RetryPolicy) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Retry = &policy
+	})
+}
+
+// WithMirror adds a URL rewrite rule for repository sources: index and archive
+// URLs starting with prefix are fetched from replacement instead. Rules are
+// tried in the order they were added.
+func WithMirror(prefix string, replacement string) RendererOption {
+	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
+		opts.Mirrors = append(opts.Mirrors, locator.Mirror{Prefix: prefix, Replacement: replacement})
+	})
+}
+
 // WithContentCache sets the path to the content-addressable cache directory.
 func WithContentCache(path string) RendererOption {
 	return util.FunctionalOption[RendererOptions](func(opts *RendererOptions) {
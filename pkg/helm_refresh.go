@@ -0,0 +1,97 @@
+package helm
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+)
+
+// Refresh re-resolves the chart of every source loaded so far and swaps in
+// the charts that changed, for example because a newer version matches
+// ReleaseVersion or because Renderer.UpdateLock moved a pin. Renders in
+// flight finish with the chart they started with, and render cache entries
+// of replaced charts are no longer hit. Sources that fail to refresh keep
+// their chart; their errors are returned joined.
+// This method is safe for concurrent use.
+func (r *Renderer) Refresh(ctx context.Context) error {
+	var errs []error
+
+	for i, holder := range r.inputs {
+		if err := holder.refresh(ctx, &r.opts); err != nil {
+			errs = append(errs, fmt.Errorf("source %d (chart %q): %w", i, holder.Chart, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// refresh reloads the chart of h if it has been loaded. A failed refresh
+// keeps the current chart and is recorded on it.
+func (h *sourceHolder) refresh(ctx context.Context, opts *RendererOptions) error {
+	h.loadMu.Lock()
+	defer h.loadMu.Unlock()
+
+	current := h.snapshot()
+	if current == nil {
+		return nil
+	}
+
+	next, err := h.load(ctx, opts, current)
+	if err != nil {
+		h.swap(current.retry(time.Now(), err))
+
+		return err
+	}
+
+	h.swap(next)
+
+	return nil
+}
+
+// refreshInterval returns the interval after which the chart of h is
+// re-resolved, or 0 if it is loaded once.
+func (h *sourceHolder) refreshInterval(opts *RendererOptions) time.Duration {
+	return max(cmp.Or(h.RefreshInterval, opts.RefreshInterval), 0)
+}
+
+// due reports whether l must be re-resolved at now.
+func (l *loadedChart) due(interval time.Duration, now time.Time) bool {
+	return interval > 0 && now.Sub(l.resolvedAt) >= interval
+}
+
+// retry returns a copy of l resolved at now, recording the error of the
+// refresh that led to it.
+func (l *loadedChart) retry(now time.Time, err error) *loadedChart {
+	next := *l
+	next.resolvedAt = now
+	next.refreshErr = err
+
+	return &next
+}
+
+// sameChart reports whether next was loaded from the same chart archive,
+// commit and dependency archives as l. Git charts have no digest and compare
+// by commit. Charts with neither, such as local directories, may have changed
+// and never compare equal.
+func (l *loadedChart) sameChart(next *loadedChart) bool {
+	if l.result.Digest == "" && l.result.Commit == "" {
+		return false
+	}
+
+	if l.result.Digest != next.result.Digest || l.result.Commit != next.result.Commit {
+		return false
+	}
+
+	if l.result.Version != next.result.Version {
+		return false
+	}
+
+	return slices.EqualFunc(l.dependencies, next.dependencies, func(a locator.Result, b locator.Result) bool {
+		return a.Digest != "" && a.Digest == b.Digest
+	})
+}
@@ -0,0 +1,275 @@
+package helm_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k8s-manifest-kit/pkg/util/cache"
+
+	helm "github.com/k8s-manifest-kit/renderer-helm/pkg"
+
+	. "github.com/onsi/gomega"
+)
+
+// refreshIndexYAML lists only version 1.1.0 of the "sub" chart, until the
+// publishing server created by newPublishingServer switches to
+// dependencyIndexYAML.
+const refreshIndexYAML = `apiVersion: v1
+entries:
+  sub:
+    - version: "1.1.0"
+      urls:
+        - sub-1.1.0.tgz
+`
+
+// Server states of newPublishingServer.
+const (
+	serverServing int32 = iota
+	serverPublished
+	serverFailing
+)
+
+func TestRefresh(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should pick up new versions on Refresh", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, state := newPublishingServer(t)
+
+		renderer, err := helm.New([]helm.Source{{
+			Repo:           srv.URL,
+			Chart:          "sub",
+			ReleaseName:    "refreshed",
+			ReleaseVersion: "^1.0.0",
+		}}, helm.WithRepositoryCache(t.TempDir()), helm.WithCache(cache.WithKeyFunc(helm.FastCacheKeyFunc)))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.1.0"))
+
+		state.Store(serverPublished)
+
+		objects, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.1.0"))
+
+		g.Expect(renderer.Refresh(t.Context())).To(Succeed())
+
+		objects, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.2.0"))
+
+		results := renderer.Results()
+		g.Expect(results).To(HaveLen(1))
+		g.Expect(results[0].Result.Version).To(Equal("1.2.0"))
+	})
+
+	t.Run("should refresh charts after the interval", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, state := newPublishingServer(t)
+
+		renderer, err := helm.New([]helm.Source{
+			{
+				Repo:           srv.URL,
+				Chart:          "sub",
+				ReleaseName:    "refreshed",
+				ReleaseVersion: "^1.0.0",
+			},
+			{
+				Repo:            srv.URL,
+				Chart:           "sub",
+				ReleaseName:     "pinned",
+				ReleaseVersion:  "^1.0.0",
+				RefreshInterval: -1,
+			},
+		}, helm.WithRepositoryCache(t.TempDir()), helm.WithRefreshInterval(time.Nanosecond))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		state.Store(serverPublished)
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.2.0"))
+		g.Expect(objects[1].Object["data"]).To(HaveKeyWithValue("version", "1.1.0"))
+	})
+
+	t.Run("should keep the loaded chart when a refresh fails", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, state := newPublishingServer(t)
+
+		renderer, err := helm.New([]helm.Source{{
+			Repo:           srv.URL,
+			Chart:          "sub",
+			ReleaseName:    "refreshed",
+			ReleaseVersion: "^1.0.0",
+		}}, helm.WithRepositoryCache(t.TempDir()), helm.WithRefreshInterval(time.Nanosecond))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		state.Store(serverFailing)
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.1.0"))
+
+		results := renderer.Results()
+		g.Expect(results).To(HaveLen(1))
+		g.Expect(helm.IsLocateError(results[0].RefreshError)).To(BeTrue())
+
+		err = renderer.Refresh(t.Context())
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+
+		state.Store(serverPublished)
+
+		g.Expect(renderer.Refresh(t.Context())).To(Succeed())
+		g.Expect(renderer.Results()[0].RefreshError).ToNot(HaveOccurred())
+	})
+
+	t.Run("should keep git charts whose commit did not change", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git is not installed")
+		}
+
+		repoDir := t.TempDir()
+		g.Expect(os.CopyFS(repoDir, subChart("1.1.0"))).To(Succeed())
+
+		git := func(args ...string) {
+			cmd := exec.CommandContext(t.Context(), "git", args...)
+			cmd.Dir = repoDir
+			cmd.Env = append(os.Environ(),
+				"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+				"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+			)
+
+			out, err := cmd.CombinedOutput()
+			g.Expect(err).ToNot(HaveOccurred(), string(out))
+		}
+
+		git("init", "--quiet", "--initial-branch=main")
+		git("add", ".")
+		git("commit", "--quiet", "--message", "add chart")
+
+		var (
+			mu   sync.Mutex
+			keys = map[string]bool{}
+		)
+
+		keyFunc := func(key any) string {
+			k := helm.FastCacheKeyFunc(key)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			keys[k] = true
+
+			return k
+		}
+
+		renderer, err := helm.New([]helm.Source{{
+			Chart:       "git+file://" + repoDir + "//sub?ref=main",
+			ReleaseName: "refreshed",
+		}}, helm.WithRepositoryCache(t.TempDir()), helm.WithCache(cache.WithKeyFunc(keyFunc)))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(renderer.Refresh(t.Context())).To(Succeed())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(keys).To(HaveLen(1))
+
+		g.Expect(os.WriteFile(
+			filepath.Join(repoDir, "sub", "Chart.yaml"),
+			[]byte("apiVersion: v2\nname: sub\nversion: 1.2.0\n"),
+			0o600,
+		)).To(Succeed())
+		git("commit", "--quiet", "--all", "--message", "bump chart")
+
+		g.Expect(renderer.Refresh(t.Context())).To(Succeed())
+
+		objects, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.2.0"))
+		g.Expect(keys).To(HaveLen(2))
+	})
+
+	t.Run("should only refresh loaded sources", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newPublishingServer(t)
+
+		renderer, err := helm.New([]helm.Source{{
+			Repo:           srv.URL,
+			Chart:          "sub",
+			ReleaseName:    "refreshed",
+			ReleaseVersion: "^1.0.0",
+		}}, helm.WithRepositoryCache(t.TempDir()))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(renderer.Refresh(t.Context())).To(Succeed())
+		g.Expect(renderer.Results()).To(BeEmpty())
+	})
+}
+
+// newPublishingServer serves a repository of the "sub" chart that publishes
+// version 1.2.0 next to 1.1.0 once its state is set to serverPublished, and
+// fails every request in serverFailing.
+func newPublishingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var state atomic.Int32
+
+	archives := map[string][]byte{
+		"/sub-1.1.0.tgz": chartArchive(t, subChart("1.1.0")),
+		"/sub-1.2.0.tgz": chartArchive(t, subChart("1.2.0")),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case state.Load() == serverFailing:
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+		case r.URL.Path == "/index.yaml" && state.Load() == serverPublished:
+			_, _ = w.Write([]byte(dependencyIndexYAML))
+		case r.URL.Path == "/index.yaml":
+			_, _ = w.Write([]byte(refreshIndexYAML))
+		case archives[r.URL.Path] != nil:
+			_, _ = w.Write(archives[r.URL.Path])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &state
+}
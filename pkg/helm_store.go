@@ -0,0 +1,317 @@
+package helm
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+)
+
+// DefaultChartStoreSize is the number of loaded charts the process-wide chart
+// store keeps by default.
+const DefaultChartStoreSize = 64
+
+// charts is shared by every Renderer in the process, so that sources and
+// renderers referencing the same chart download and load it once.
+//
+//nolint:gochecknoglobals // process-wide store by design
+var charts = newChartStore(DefaultChartStoreSize)
+
+// SetChartStoreSize bounds the number of loaded charts kept by the
+// process-wide chart store. The least recently used charts are dropped first;
+// 0 disables keeping loaded charts. Concurrent locates of the same chart are
+// shared regardless.
+func SetChartStoreSize(size int) {
+	charts.resize(size)
+}
+
+// chartStore deduplicates locates and chart loads. Concurrent locates of
+// equivalent requests share one locator.Locate call, and charts loaded from
+// archives are kept by digest in an LRU. Stored charts are never handed out
+// directly: every caller gets its own copy, since rendering modifies charts.
+type chartStore struct {
+	group singleflight.Group
+
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// storedChart is an LRU element of chartStore.
+type storedChart struct {
+	digest string
+	chart  *chart.Chart
+}
+
+func newChartStore(size int) *chartStore {
+	return &chartStore{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// locate runs locator.Locate for req, sharing the call with concurrent
+// locates of an equivalent request, also across renderers. Requests with
+// Credentials, or with credential providers, locators or getters that are
+// functions, are never shared: closures cannot be told apart, so sources
+// authenticating as different users would otherwise receive each other's
+// charts. A caller whose own context is still live locates again if the
+// shared call was cancelled by another caller.
+func (s *chartStore) locate(ctx context.Context, req *locator.Request) (locator.Result, error) {
+	key, ok := requestKey(req)
+	if req.Credentials != nil || !ok {
+		return locator.Locate(ctx, req) //nolint:wrapcheck // callers wrap locate errors
+	}
+
+	v, err, shared := s.group.Do("locate\x00"+key, func() (any, error) {
+		return locator.Locate(ctx, req)
+	})
+
+	if err != nil && shared && ctx.Err() == nil &&
+		(errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return locator.Locate(ctx, req)
+	}
+
+	if err != nil {
+		return locator.Result{}, err //nolint:wrapcheck // callers wrap locate errors
+	}
+
+	result, _ := v.(locator.Result)
+
+	return result, nil
+}
+
+// load loads the chart located by result. Charts of archives with a known
+// digest are loaded once and kept; other charts, such as local directories
+// that may change, are always loaded from disk.
+func (s *chartStore) load(result locator.Result) (*chart.Chart, error) {
+	if result.Digest == "" {
+		return loader.Load(result.Path) //nolint:wrapcheck // callers wrap load errors
+	}
+
+	if c := s.lookup(result.Digest); c != nil {
+		return copyChart(c), nil
+	}
+
+	v, err, _ := s.group.Do("load\x00"+result.Digest, func() (any, error) {
+		c, err := loader.Load(result.Path)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // callers wrap load errors
+		}
+
+		s.store(result.Digest, c)
+
+		return c, nil
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // callers wrap load errors
+	}
+
+	c, _ := v.(*chart.Chart)
+
+	return copyChart(c), nil
+}
+
+// lookup returns the stored chart with digest, or nil.
+func (s *chartStore) lookup(digest string) *chart.Chart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[digest]
+	if !ok {
+		return nil
+	}
+
+	s.lru.MoveToFront(e)
+
+	stored, _ := e.Value.(*storedChart)
+
+	return stored.chart
+}
+
+// store adds c under digest, dropping the least recently used charts beyond
+// the store size.
+func (s *chartStore) store(digest string, c *chart.Chart) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size <= 0 {
+		return
+	}
+
+	if e, ok := s.entries[digest]; ok {
+		s.lru.MoveToFront(e)
+
+		return
+	}
+
+	s.entries[digest] = s.lru.PushFront(&storedChart{digest: digest, chart: c})
+	s.evict()
+}
+
+func (s *chartStore) resize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.size = size
+	s.evict()
+}
+
+// evict drops the least recently used charts beyond the store size. The
+// caller must hold mu.
+func (s *chartStore) evict() {
+	for s.lru.Len() > max(s.size, 0) {
+		e := s.lru.Back()
+		stored, _ := e.Value.(*storedChart)
+
+		s.lru.Remove(e)
+		delete(s.entries, stored.digest)
+	}
+}
+
+// requestKey identifies the chart a locator request without Credentials
+// resolves to. Settings are compared by value, and credential providers,
+// clients, locators and getters by instance, so that requests of renderers
+// configured alike share a locate while requests that would fetch the chart
+// differently do not. It reports false when the request holds a function,
+// which has no identity to compare.
+func requestKey(req *locator.Request) (string, bool) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%q|%q|%q|%q|%+v|%q|%q|%+v|%t|%+v|%t|%t|%q|%+v|%t|%+v",
+		req.Name,
+		req.RepoURL,
+		req.Version,
+		req.Digest,
+		req.ResolutionPolicy,
+		req.RepositoryConfig,
+		req.RepositoryCache,
+		req.TLS,
+		req.PlainHTTP,
+		req.Registries,
+		req.RequireDigest,
+		req.Verify,
+		req.Keyring,
+		req.Mirrors,
+		req.Offline,
+		req.Retry,
+	)
+
+	values := []any{req.HTTPClient, req.Transport}
+	for _, p := range req.CredentialProviders {
+		values = append(values, p)
+	}
+
+	for _, scheme := range slices.Sorted(maps.Keys(req.Locators)) {
+		values = append(values, scheme, req.Locators[scheme])
+	}
+
+	for _, scheme := range slices.Sorted(maps.Keys(req.Getters)) {
+		values = append(values, scheme, req.Getters[scheme])
+	}
+
+	for _, v := range values {
+		id, ok := identity(v)
+		if !ok {
+			return "", false
+		}
+
+		b.WriteString("|" + id)
+	}
+
+	return b.String(), true
+}
+
+// identity returns a key that is equal for the same instance of v: pointers
+// and maps by address, other values by content. It reports false for
+// functions, whose closures cannot be told apart.
+func identity(v any) (string, bool) {
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return "<nil>", true
+	case reflect.Func:
+		return "", false
+	case reflect.Pointer, reflect.Map, reflect.Chan, reflect.UnsafePointer:
+		return fmt.Sprintf("%T@%#x", v, rv.Pointer()), true
+	default:
+		return fmt.Sprintf("%T:%+v", v, v), true
+	}
+}
+
+// copyChart returns a copy of c that can be modified, for example by
+// chartutil.ProcessDependencies or by adding built dependencies, without
+// affecting c. Files and templates are shared, as they are never modified.
+func copyChart(c *chart.Chart) *chart.Chart {
+	cp := *c
+
+	if c.Metadata != nil {
+		md := *c.Metadata
+
+		md.Dependencies = make([]*chart.Dependency, len(c.Metadata.Dependencies))
+		for i, dep := range c.Metadata.Dependencies {
+			if dep != nil {
+				d := *dep
+				md.Dependencies[i] = &d
+			}
+		}
+
+		cp.Metadata = &md
+	}
+
+	values, _ := copyValue(c.Values).(map[string]any)
+	cp.Values = values
+
+	dependencies := make([]*chart.Chart, 0, len(c.Dependencies()))
+	for _, sub := range c.Dependencies() {
+		dependencies = append(dependencies, copyChart(sub))
+	}
+
+	cp.SetDependencies(dependencies...)
+
+	return &cp
+}
+
+// copyValue deep-copies the maps and slices of a chart values tree.
+func copyValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		if t == nil {
+			return t
+		}
+
+		m := make(map[string]any, len(t))
+		for k, e := range t {
+			m[k] = copyValue(e)
+		}
+
+		return m
+	case []any:
+		if t == nil {
+			return t
+		}
+
+		s := make([]any, len(t))
+		for i, e := range t {
+			s[i] = copyValue(e)
+		}
+
+		return s
+	default:
+		return v
+	}
+}
@@ -0,0 +1,226 @@
+package helm_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	helm "github.com/k8s-manifest-kit/renderer-helm/pkg"
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestChartStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should share one download between concurrent sources", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var downloads atomic.Int32
+
+		archive := chartArchive(t, subChart("1.2.0"))
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/index.yaml":
+				// Keep the locate in flight until both sources asked for it
+				time.Sleep(200 * time.Millisecond)
+
+				_, _ = w.Write([]byte(dependencyIndexYAML))
+			case "/sub-1.2.0.tgz":
+				downloads.Add(1)
+
+				_, _ = w.Write(archive)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		renderer, err := helm.New([]helm.Source{
+			{
+				Repo:           srv.URL,
+				Chart:          "sub",
+				ReleaseName:    "first",
+				ReleaseVersion: "^1.0.0",
+			},
+			{
+				Repo:           srv.URL,
+				Chart:          "sub",
+				ReleaseName:    "second",
+				ReleaseVersion: "^1.0.0",
+			},
+		}, helm.WithRepositoryCache(t.TempDir()), helm.WithConcurrency(2))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+		g.Expect(downloads.Load()).To(Equal(int32(1)))
+
+		for _, obj := range objects {
+			g.Expect(obj.Object["data"]).To(HaveKeyWithValue("version", "1.2.0"))
+		}
+	})
+
+	t.Run("should share one download between renderers configured alike", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var downloads atomic.Int32
+
+		archive := chartArchive(t, subChart("1.2.0"))
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/index.yaml":
+				// Keep the locate in flight until both renderers asked for it
+				time.Sleep(200 * time.Millisecond)
+
+				_, _ = w.Write([]byte(dependencyIndexYAML))
+			case "/sub-1.2.0.tgz":
+				downloads.Add(1)
+
+				_, _ = w.Write(archive)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		cacheDir := t.TempDir()
+		provider := &locator.NetrcProvider{Path: filepath.Join(t.TempDir(), "netrc")}
+
+		var wg sync.WaitGroup
+		for _, name := range []string{"first", "second"} {
+			renderer, err := helm.New([]helm.Source{{
+				Repo:           srv.URL,
+				Chart:          "sub",
+				ReleaseName:    name,
+				ReleaseVersion: "^1.0.0",
+			}},
+				helm.WithRepositoryCache(cacheDir),
+				helm.WithCredentialProvider(provider),
+				helm.WithTLSConfig(locator.TLSConfig{ServerName: "127.0.0.1"}),
+			)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			wg.Go(func() {
+				objects, err := renderer.Process(t.Context(), nil)
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(objects).To(HaveLen(1))
+			})
+		}
+
+		wg.Wait()
+		g.Expect(downloads.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("should not share locates between sources with credentials", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var (
+			mu    sync.Mutex
+			users []string
+		)
+
+		archive := chartArchive(t, subChart("1.2.0"))
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _, ok := r.BasicAuth()
+			if !ok {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			switch r.URL.Path {
+			case "/index.yaml":
+				mu.Lock()
+				users = append(users, user)
+				mu.Unlock()
+
+				// Keep the locate in flight until both sources asked for it
+				time.Sleep(200 * time.Millisecond)
+
+				_, _ = w.Write([]byte(dependencyIndexYAML))
+			case "/sub-1.2.0.tgz":
+				_, _ = w.Write(archive)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		sources := make([]helm.Source, 0, 2)
+		for _, user := range []string{"alice", "bob"} {
+			sources = append(sources, helm.Source{
+				Repo:           srv.URL,
+				Chart:          "sub",
+				ReleaseName:    user,
+				ReleaseVersion: "^1.0.0",
+				Credentials:    helm.StaticCredentials(user, user+"-pass"),
+			})
+		}
+
+		renderer, err := helm.New(sources, helm.WithRepositoryCache(t.TempDir()), helm.WithConcurrency(2))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		g.Expect(users).To(ConsistOf("alice", "bob"))
+	})
+
+	t.Run("should keep sources sharing a chart independent", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDependencyServer(t)
+
+		chartYAML := "apiVersion: v2\nname: parent\nversion: 1.0.0\ndependencies:\n" +
+			"  - name: sub\n    version: \"^1.0.0\"\n    repository: \"" + srv.URL + "\"\n"
+
+		archivePath := filepath.Join(t.TempDir(), "parent-1.0.0.tgz")
+		g.Expect(os.WriteFile(archivePath, chartArchive(t, fstest.MapFS{
+			"parent/Chart.yaml": {Data: []byte(chartYAML)},
+		}), 0600)).To(Succeed())
+
+		renderer, err := helm.New([]helm.Source{
+			{
+				Chart:             archivePath,
+				ReleaseName:       "built",
+				BuildDependencies: true,
+			},
+			{
+				Chart:       archivePath,
+				ReleaseName: "plain",
+			},
+		}, helm.WithRepositoryCache(t.TempDir()))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(1))
+		g.Expect(objects[0].Object["data"]).To(HaveKeyWithValue("version", "1.2.0"))
+
+		results := renderer.Results()
+		g.Expect(results).To(HaveLen(2))
+		g.Expect(results[0].Result.Digest).To(Equal(results[1].Result.Digest))
+		g.Expect(results[0].Dependencies).To(HaveLen(1))
+		g.Expect(results[1].Dependencies).To(BeEmpty())
+	})
+}
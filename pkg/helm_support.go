@@ -1,16 +1,19 @@
 package helm
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	chart "helm.sh/helm/v4/pkg/chart/v2"
-	"helm.sh/helm/v4/pkg/chart/v2/loader"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -45,6 +48,12 @@ var (
 			"and must start and end with an alphanumeric character",
 	)
 
+	// ErrSourceConflict is returned when more than one of Repo, FS and Loaded is set.
+	ErrSourceConflict = errors.New("only one of Repo, FS and Loaded can be set")
+
+	// ErrLoadedChartInvalid is returned when a loaded chart has no metadata.
+	ErrLoadedChartInvalid = errors.New("loaded chart has no metadata")
+
 	// releaseNameRegex is the compiled regex for validating release names.
 	releaseNameRegex = regexp.MustCompile(releaseNamePattern)
 )
@@ -68,19 +77,72 @@ func StaticCredentials(username string, password string) func(context.Context) (
 	}
 }
 
+// defaultKeyring returns the keyring Helm uses for provenance verification:
+// $GNUPGHOME/pubring.gpg, falling back to ~/.gnupg/pubring.gpg.
+func defaultKeyring() string {
+	if home := os.Getenv("GNUPGHOME"); home != "" {
+		return filepath.Join(home, "pubring.gpg")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".gnupg", "pubring.gpg")
+}
+
 // sourceHolder wraps a Source with internal state for lazy loading and thread-safety.
 type sourceHolder struct {
 	Source
 
-	// Mutex protects concurrent access to chart field
+	// Mutex protects concurrent access to loaded
 	mu *sync.RWMutex
 
-	// The loaded Helm chart (protected by mu)
+	// Mutex serializing loads and refreshes, so that only one runs at a time
+	loadMu *sync.Mutex
+
+	// The chart the source was last loaded from (protected by mu)
+	loaded *loadedChart
+
+	// The lockfile of the Renderer, nil without one
+	lock *lockState
+}
+
+// loadedChart is a snapshot of a loaded chart. Refreshes replace the snapshot
+// of a source as a whole rather than modifying it, so renders in flight keep
+// using the chart they started with.
+type loadedChart struct {
+	// The loaded Helm chart
 	chart *chart.Chart
+
+	// The locator result the chart was loaded from
+	result locator.Result
+
+	// The locator results of the dependencies fetched for the chart
+	dependencies []locator.Result
+
+	// When the chart was last resolved, successfully or not
+	resolvedAt time.Time
+
+	// The error of the last failed interval refresh, nil after a successful one
+	refreshErr error
+
+	// Counts the charts loaded for the source, starting at 1
+	generation uint64
 }
 
 // Validate checks if the Source configuration is valid.
 func (h *sourceHolder) Validate() error {
+	switch {
+	case h.FS != nil && h.Loaded != nil:
+		return &ValidationError{Field: "FS", Err: ErrSourceConflict}
+	case (h.FS != nil || h.Loaded != nil) && h.Repo != "":
+		return &ValidationError{Field: "Repo", Err: ErrSourceConflict}
+	case h.Loaded != nil && h.Loaded.Metadata == nil:
+		return &ValidationError{Field: "Loaded", Err: ErrLoadedChartInvalid}
+	}
+
 	if len(strings.TrimSpace(h.Chart)) == 0 {
 		return &ValidationError{Field: "Chart", Err: ErrChartEmpty}
 	}
@@ -113,47 +175,154 @@ func (h *sourceHolder) Validate() error {
 	return nil
 }
 
-// LoadChart returns the loaded Helm chart, loading it lazily if needed.
+// LoadChart returns the loaded Helm chart, loading it lazily if needed and
+// refreshing it once its refresh interval has passed.
 // Thread-safe for concurrent use with optimized read-path performance.
 func (h *sourceHolder) LoadChart(
 	ctx context.Context,
-	repositoryCache string,
-) (*chart.Chart, error) {
-	// Fast path: read lock for checking if chart is already loaded
-	// Multiple goroutines can check concurrently
-	h.mu.RLock()
-	if h.chart != nil {
-		c := h.chart
-		h.mu.RUnlock()
-
-		return c, nil
+	opts *RendererOptions,
+) (*loadedChart, error) {
+	interval := h.refreshInterval(opts)
+
+	// Fast path: the current snapshot is returned as long as it is not due
+	// for a refresh, or while another goroutine refreshes it
+	loaded := h.snapshot()
+	if loaded != nil {
+		if !loaded.due(interval, time.Now()) || !h.loadMu.TryLock() {
+			return loaded, nil
+		}
+	} else {
+		// Slow path: only one goroutine loads at a time
+		h.loadMu.Lock()
 	}
-	h.mu.RUnlock()
-
-	// Slow path: write lock for loading chart
-	// Only one goroutine loads at a time
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	defer h.loadMu.Unlock()
 
 	// Double-check: another goroutine might have loaded while we waited for lock
-	if h.chart != nil {
-		return h.chart, nil
+	current := h.snapshot()
+	if current != nil && !current.due(interval, time.Now()) {
+		return current, nil
 	}
 
 	// Check context before starting the expensive load operation
 	if err := ctx.Err(); err != nil {
+		if current != nil {
+			return current, nil
+		}
+
 		return nil, fmt.Errorf("context cancelled during chart load: %w", err)
 	}
 
-	result, err := locator.Locate(ctx, &locator.Request{
-		Name:            h.Chart,
-		RepoURL:         h.Repo,
-		Version:         h.ReleaseVersion,
-		Credentials:     h.Credentials,
-		RepositoryCache: repositoryCache,
-	})
+	next, err := h.load(ctx, opts, current)
 	if err != nil {
-		return nil, &LocateError{
+		if current == nil {
+			return nil, err
+		}
+
+		// Keep rendering the current chart and try again after the interval
+		next = current.retry(time.Now(), err)
+	}
+
+	h.swap(next)
+
+	return next, nil
+}
+
+// load loads the chart of the source. When current is set and the chart did
+// not change, current is returned with an updated resolution time, so the
+// render cache entries of its generation stay valid.
+func (h *sourceHolder) load(
+	ctx context.Context,
+	opts *RendererOptions,
+	current *loadedChart,
+) (*loadedChart, error) {
+	var (
+		c      *chart.Chart
+		result locator.Result
+		err    error
+	)
+
+	if h.FS != nil || h.Loaded != nil {
+		c, result, err = h.providedChart(opts)
+	} else {
+		c, result, err = h.locateChart(ctx, opts)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var dependencies []locator.Result
+	if h.BuildDependencies {
+		dependencies, err = h.buildDependencies(ctx, opts, c, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	next := &loadedChart{
+		chart:        c,
+		result:       result,
+		dependencies: dependencies,
+		resolvedAt:   time.Now(),
+		generation:   1,
+	}
+
+	if current != nil {
+		if current.sameChart(next) {
+			return current.retry(next.resolvedAt, nil), nil
+		}
+
+		next.generation = current.generation + 1
+	}
+
+	return next, nil
+}
+
+// snapshot returns the current chart snapshot, nil before the first load.
+func (h *sourceHolder) snapshot() *loadedChart {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.loaded
+}
+
+// swap replaces the chart snapshot.
+func (h *sourceHolder) swap(loaded *loadedChart) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.loaded = loaded
+}
+
+// locateChart resolves the chart through the locator and loads it from the
+// path it was located at, sharing both with other sources through the
+// process-wide chart store.
+func (h *sourceHolder) locateChart(
+	ctx context.Context,
+	opts *RendererOptions,
+) (*chart.Chart, locator.Result, error) {
+	entry := h.lock.lookup(h)
+	if err := h.lock.require(h, entry); err != nil {
+		return nil, locator.Result{}, err
+	}
+
+	req := h.locatorRequest(opts)
+	pinRequest(req, entry)
+
+	result, err := charts.locate(ctx, req)
+	if err != nil && req.Digest != "" && locator.IsDigestMismatchError(err) {
+		return nil, locator.Result{}, lockError(h, fmt.Errorf(
+			"%w: chart %s %s no longer has digest %s: %w",
+			ErrLockStale,
+			h.Chart,
+			entry.Version,
+			entry.Digest,
+			err,
+		))
+	}
+
+	if err != nil {
+		return nil, locator.Result{}, &LocateError{
 			Chart:   h.Chart,
 			Repo:    h.Repo,
 			Version: h.ReleaseVersion,
@@ -167,9 +336,9 @@ func (h *sourceHolder) LoadChart(
 		}
 	}
 
-	c, err := loader.Load(result.Path)
+	c, err := charts.load(result)
 	if err != nil {
-		return nil, &LocateError{
+		return nil, locator.Result{}, &LocateError{
 			Chart:   h.Chart,
 			Repo:    h.Repo,
 			Version: h.ReleaseVersion,
@@ -183,9 +352,42 @@ func (h *sourceHolder) LoadChart(
 		}
 	}
 
-	h.chart = c
+	if result.Metadata == nil {
+		result.Metadata = c.Metadata
+	}
+
+	if err := h.lock.settle(h, entry, result); err != nil {
+		return nil, locator.Result{}, err
+	}
+
+	return c, result, nil
+}
 
-	return h.chart, nil
+// locatorRequest returns the locator request for the chart of the source.
+func (h *sourceHolder) locatorRequest(opts *RendererOptions) *locator.Request {
+	return &locator.Request{
+		Name:                h.Chart,
+		RepoURL:             h.Repo,
+		Version:             h.ReleaseVersion,
+		ResolutionPolicy:    h.ResolutionPolicy,
+		Credentials:         h.Credentials,
+		CredentialProviders: opts.CredentialProviders,
+		RepositoryConfig:    opts.RepositoryConfig,
+		RepositoryCache:     opts.RepositoryCache,
+		TLS:                 cmp.Or(h.TLS, opts.TLS),
+		HTTPClient:          opts.HTTPClient,
+		Transport:           opts.Transport,
+		PlainHTTP:           h.PlainHTTP,
+		Registries:          opts.Registries,
+		Locators:            opts.Locators,
+		Getters:             opts.Getters,
+		Retry:               opts.Retry,
+		Mirrors:             opts.Mirrors,
+		RequireDigest:       opts.RequireDigest,
+		Verify:              h.Verify || opts.Verify,
+		Keyring:             opts.Keyring,
+		Offline:             opts.Offline,
+	}
 }
 
 // addContentHash computes and adds a content hash annotation to each object.
@@ -204,13 +406,16 @@ func (r *Renderer) addContentHash(objects []unstructured.Unstructured) {
 // Only modifies objects if source annotations are enabled in renderer options.
 func (r *Renderer) addSourceAnnotations(
 	objects []unstructured.Unstructured,
-	chartPath string,
+	holder *sourceHolder,
+	loaded *loadedChart,
 	fileName string,
 ) {
 	if !r.opts.SourceAnnotations {
 		return
 	}
 
+	commit := loaded.result.Commit
+
 	for i := range objects {
 		annotations := objects[i].GetAnnotations()
 		if annotations == nil {
@@ -218,9 +423,13 @@ func (r *Renderer) addSourceAnnotations(
 		}
 
 		annotations[types.AnnotationSourceType] = rendererType
-		annotations[types.AnnotationSourcePath] = chartPath
+		annotations[types.AnnotationSourcePath] = holder.Chart
 		annotations[types.AnnotationSourceFile] = fileName
 
+		if commit != "" {
+			annotations[AnnotationSourceCommit] = commit
+		}
+
 		objects[i].SetAnnotations(annotations)
 	}
 }
@@ -228,18 +437,18 @@ func (r *Renderer) addSourceAnnotations(
 // processCRDs extracts and processes CRD objects from a Helm chart.
 // Returns the decoded unstructured objects with source annotations added if enabled.
 func (r *Renderer) processCRDs(
-	helmChart *chart.Chart,
+	loaded *loadedChart,
 	holder *sourceHolder,
 ) ([]unstructured.Unstructured, error) {
 	result := make([]unstructured.Unstructured, 0)
 
-	for _, crd := range helmChart.CRDObjects() {
+	for _, crd := range loaded.chart.CRDObjects() {
 		objects, err := k8s.DecodeYAML(crd.File.Data)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode CRD %s: %w", crd.Name, err)
 		}
 
-		r.addSourceAnnotations(objects, holder.Chart, crd.Name)
+		r.addSourceAnnotations(objects, holder, loaded, crd.Name)
 		r.addContentHash(objects)
 		result = append(result, objects...)
 	}
@@ -253,6 +462,7 @@ func (r *Renderer) processCRDs(
 func (r *Renderer) processRenderedTemplates(
 	files map[string]string,
 	holder *sourceHolder,
+	loaded *loadedChart,
 ) ([]unstructured.Unstructured, error) {
 	result := make([]unstructured.Unstructured, 0)
 
@@ -275,7 +485,7 @@ func (r *Renderer) processRenderedTemplates(
 			)
 		}
 
-		r.addSourceAnnotations(objects, holder.Chart, k)
+		r.addSourceAnnotations(objects, holder, loaded, k)
 		r.addContentHash(objects)
 		result = append(result, objects...)
 	}
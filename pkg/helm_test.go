@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/rs/xid"
 
@@ -101,6 +107,30 @@ func TestRenderer(t *testing.T) {
 		g.Expect(foundService).To(BeTrue(), "Should have rendered Service")
 	})
 
+	t.Run("should report what each source resolved to", func(t *testing.T) {
+		g := NewWithT(t)
+		renderer, err := helm.New([]helm.Source{
+			{
+				Chart:       testChartPath,
+				ReleaseName: "results-test",
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(renderer.Results()).To(BeEmpty())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		results := renderer.Results()
+		g.Expect(results).To(HaveLen(1))
+		g.Expect(results[0].Index).To(Equal(0))
+		g.Expect(results[0].Source.ReleaseName).To(Equal("results-test"))
+		g.Expect(results[0].Result.SourceType).To(Equal(locator.SourceLocal))
+		g.Expect(results[0].Result.Metadata).ToNot(BeNil())
+		g.Expect(results[0].Result.Metadata.Name).To(Equal("simple-app"))
+		g.Expect(results[0].Result.Metadata.Version).To(Equal("1.0.0"))
+	})
+
 	t.Run("should render with dynamic values", func(t *testing.T) {
 		g := NewWithT(t)
 		dynamicValues := func(_ context.Context) (types.Values, error) {
@@ -949,6 +979,63 @@ func TestSourceAnnotations(t *testing.T) {
 		}
 	})
 
+	t.Run("should record the commit of git charts", func(t *testing.T) {
+		g := NewWithT(t)
+
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git is not installed")
+		}
+
+		repoDir := t.TempDir()
+		g.Expect(os.CopyFS(filepath.Join(repoDir, "charts", "simple-app"), os.DirFS(testChartPath))).To(Succeed())
+
+		git := func(args ...string) string {
+			cmd := exec.CommandContext(t.Context(), "git", args...)
+			cmd.Dir = repoDir
+			cmd.Env = append(os.Environ(),
+				"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+				"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+			)
+
+			out, err := cmd.CombinedOutput()
+			g.Expect(err).ToNot(HaveOccurred(), string(out))
+
+			return strings.TrimSpace(string(out))
+		}
+
+		git("init", "--quiet")
+		git("add", ".")
+		git("commit", "--quiet", "--message", "add chart")
+		commit := git("rev-parse", "HEAD")
+
+		chartRef := "git+file://" + repoDir + "//charts/simple-app"
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Chart:       chartRef,
+				ReleaseName: "git-annotations-test",
+			}},
+			helm.WithRepositoryCache(t.TempDir()),
+			helm.WithSourceAnnotations(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).ToNot(BeEmpty())
+
+		for _, obj := range objects {
+			annotations := obj.GetAnnotations()
+			g.Expect(annotations).Should(HaveKeyWithValue(types.AnnotationSourcePath, chartRef))
+			g.Expect(annotations).Should(HaveKeyWithValue(helm.AnnotationSourceCommit, commit))
+		}
+
+		results := renderer.Results()
+		g.Expect(results).To(HaveLen(1))
+		g.Expect(results[0].Result.Commit).To(Equal(commit))
+		g.Expect(results[0].Result.SourceType).To(Equal(locator.SourceGit))
+	})
+
 	t.Run("should not add source annotations when disabled", func(t *testing.T) {
 		g := NewWithT(t)
 		renderer, err := helm.New([]helm.Source{
@@ -1441,6 +1528,255 @@ data:
 		g.Expect(err).ToNot(HaveOccurred())
 		g.Expect(objects).To(HaveLen(3))
 	})
+
+	t.Run("should resolve repo aliases through the configured repositories file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		config := filepath.Join(t.TempDir(), "repositories.yaml")
+		err := os.WriteFile(config, []byte("repositories:\n  - name: known\n    url: https://charts.example.com\n"), 0600)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Chart:       "unknown/mychart",
+				ReleaseName: "alias-test",
+			}},
+			helm.WithRepositoryConfig(config),
+			helm.WithRepositoryCache(t.TempDir()),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, locator.ErrRepositoryNotFound)).To(BeTrue())
+	})
+
+	t.Run("should fail with a not-cached error in offline mode", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Repo:           "https://charts.example.com",
+				Chart:          "mychart",
+				ReleaseName:    "offline-test",
+				ReleaseVersion: "1.0.0",
+			}},
+			helm.WithRepositoryCache(t.TempDir()),
+			helm.WithOffline(true),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(locator.IsNotCachedError(err)).To(BeTrue())
+	})
+
+	t.Run("should fetch repository indexes through configured mirrors", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var requested string
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = r.URL.Path
+			http.NotFound(w, r)
+		}))
+		t.Cleanup(srv.Close)
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Repo:        "https://charts.example.com/stable",
+				Chart:       "mychart",
+				ReleaseName: "mirror-test",
+			}},
+			helm.WithRepositoryCache(t.TempDir()),
+			helm.WithMirror("https://charts.example.com", srv.URL+"/mirror"),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(requested).To(Equal("/mirror/stable/index.yaml"))
+	})
+
+	t.Run("should send repository requests through the configured transport", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var requested string
+
+		transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			requested = r.URL.String()
+
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       http.NoBody,
+				Request:    r,
+			}, nil
+		})
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Repo:        "https://charts.example.com/stable",
+				Chart:       "mychart",
+				ReleaseName: "transport-test",
+			}},
+			helm.WithRepositoryCache(t.TempDir()),
+			helm.WithTransport(transport),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, locator.ErrUnexpectedStatus)).To(BeTrue())
+		g.Expect(requested).To(Equal("https://charts.example.com/stable/index.yaml"))
+	})
+
+	t.Run("should fetch custom scheme repositories through the getter", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var requested string
+
+		getter := locator.GetterFunc(func(_ context.Context, req *locator.GetterRequest) ([]byte, error) {
+			requested = req.URL
+
+			return nil, errors.New("bucket not found")
+		})
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Repo:        "s3://bucket/charts",
+				Chart:       "mychart",
+				ReleaseName: "getter-test",
+			}},
+			helm.WithRepositoryCache(t.TempDir()),
+			helm.WithGetter("S3", getter),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(err).To(MatchError(ContainSubstring("bucket not found")))
+		g.Expect(requested).To(Equal("s3://bucket/charts/index.yaml"))
+	})
+
+	t.Run("should locate custom scheme charts through the registered locator", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		absPath, err := filepath.Abs(testChartPath)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Chart:       "store://charts/simple-app",
+				ReleaseName: "locator-test",
+			}},
+			helm.WithLocator("store", func(_ context.Context, req *locator.SchemeRequest) (locator.Locator, error) {
+				g.Expect(req.Name).To(Equal("store://charts/simple-app"))
+
+				return &locator.Local{Name: absPath}, nil
+			}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := renderer.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).ToNot(BeEmpty())
+	})
+
+	t.Run("should download archive URLs directly", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var requested string
+
+		transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			requested = r.URL.String()
+
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       http.NoBody,
+				Request:    r,
+			}, nil
+		})
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Chart:       "https://example.com/releases/mychart-1.2.3.tgz",
+				ReleaseName: "archive-test",
+			}},
+			helm.WithRepositoryCache(t.TempDir()),
+			helm.WithTransport(transport),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, locator.ErrUnexpectedStatus)).To(BeTrue())
+		g.Expect(requested).To(Equal("https://example.com/releases/mychart-1.2.3.tgz"))
+	})
+
+	t.Run("should retry transient repository failures", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var requests atomic.Int32
+
+		transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			requests.Add(1)
+
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       http.NoBody,
+				Request:    r,
+			}, nil
+		})
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Repo:        "https://charts.example.com/stable",
+				Chart:       "mychart",
+				ReleaseName: "retry-test",
+			}},
+			helm.WithRepositoryCache(t.TempDir()),
+			helm.WithTransport(transport),
+			helm.WithRetry(locator.RetryPolicy{Attempts: 3, InitialBackoff: time.Millisecond}),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(locator.IsRetryError(err)).To(BeTrue())
+		g.Expect(requests.Load()).To(Equal(int32(3)))
+	})
+
+	t.Run("should refuse unsigned charts when verification is enabled", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		renderer, err := helm.New(
+			[]helm.Source{{
+				Chart:       testChartPath,
+				ReleaseName: "verify-test",
+				Verify:      true,
+			}},
+			helm.WithVerification(filepath.Join(t.TempDir(), "pubring.gpg")),
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(errors.Is(err, locator.ErrProvenanceNotFound)).To(BeTrue())
+	})
 }
 
 func TestRendererRemoteSources(t *testing.T) {
@@ -1561,6 +1897,73 @@ func TestRendererRemoteSources(t *testing.T) {
 	})
 }
 
+func TestConcurrency(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should return objects in source order", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		versions := []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0"}
+
+		sources := make([]helm.Source, 0, len(versions))
+		for _, version := range versions {
+			sources = append(sources, helm.Source{
+				FS:          subChart(version),
+				Chart:       "sub",
+				ReleaseName: "sub-" + version,
+			})
+		}
+
+		sequential, err := helm.New(sources)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		expected, err := sequential.Process(t.Context(), nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		renderer, err := helm.New(sources, helm.WithConcurrency(len(sources)))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		for range 3 {
+			objects, err := renderer.Process(t.Context(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(objects).To(Equal(expected))
+			g.Expect(objects).To(HaveLen(len(versions)))
+
+			for i, version := range versions {
+				g.Expect(objects[i].Object["data"]).To(HaveKeyWithValue("version", version))
+			}
+		}
+	})
+
+	t.Run("should cancel the remaining sources on the first error", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		t.Cleanup(srv.Close)
+
+		renderer, err := helm.New([]helm.Source{
+			{
+				Repo:        srv.URL,
+				Chart:       "sub",
+				ReleaseName: "blocked",
+			},
+			{
+				Chart:       filepath.Join(t.TempDir(), "missing"),
+				ReleaseName: "missing",
+			},
+		}, helm.WithRepositoryCache(t.TempDir()), helm.WithConcurrency(2))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = renderer.Process(t.Context(), nil)
+		g.Expect(helm.IsLocateError(err)).To(BeTrue())
+		g.Expect(err.Error()).To(ContainSubstring("missing"))
+	})
+}
+
 func objectIdentities(objects []unstructured.Unstructured) []string {
 	ids := make([]string, 0, len(objects))
 	for _, obj := range objects {
@@ -1572,3 +1975,10 @@ func objectIdentities(objects []unstructured.Unstructured) []string {
 
 	return ids
 }
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,53 @@
+// Package fsutil holds file system helpers shared by the renderer and the
+// locator.
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// dirPermissions is used for directories created for written files.
+const dirPermissions = 0750
+
+// WriteFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it into place, so concurrent readers never observe a partially
+// written file and a crash leaves either the old or the new content. Missing
+// directories are created with mode 0750 and the file gets mode 0600.
+func WriteFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirPermissions); err != nil {
+		return fmt.Errorf("unable to create directory %q: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("unable to write %q: %w", tmpName, err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("unable to sync %q: %w", tmpName, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to close %q: %w", tmpName, err)
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("unable to rename %q to %q: %w", tmpName, path, err)
+	}
+
+	return nil
+}
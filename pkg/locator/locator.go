@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/container"
 )
 
 var (
@@ -20,10 +23,9 @@ var (
 	ErrEmptyCacheDir = errors.New("cache directory must not be empty")
 )
 
-const (
-	dirPermissions  = 0750
-	filePermissions = 0600
-)
+// dirPermissions is used for cache directories. Cached files are created
+// through os.CreateTemp and are therefore always 0600.
+const dirPermissions = 0750
 
 // Locator resolves a chart reference to a local filesystem path.
 type Locator interface {
@@ -31,13 +33,79 @@ type Locator interface {
 }
 
 // Credentials holds authentication credentials for accessing Helm repositories and registries.
+// Repository credentials are only sent to the repository origin unless the
+// repository passes credentials to all hosts.
 type Credentials struct {
 	Username string
 	Password string //nolint:gosec // not a hardcoded credential, just a field name
+
+	// BearerToken is sent as "Authorization: Bearer <token>" to repositories,
+	// replacing basic auth. For OCI registries it is used as the access token.
+	BearerToken string //nolint:gosec // not a hardcoded credential, just a field name
+
+	// RefreshToken is an OCI identity token, as stored by "docker login" or
+	// "helm registry login", exchanged for access tokens at the registry's
+	// token service. Repositories ignore it.
+	RefreshToken string //nolint:gosec // not a hardcoded credential, just a field name
+
+	// Headers are added to every request sent to the repository or registry
+	// host, e.g. {"X-JFrog-Art-Api": key}. They are never sent to other hosts,
+	// including redirect targets.
+	Headers map[string]string
 }
 
 func (c *Credentials) hasAuth() bool {
-	return c != nil && (c.Username != "" || c.Password != "")
+	return c != nil &&
+		(c.Username != "" || c.Password != "" || c.BearerToken != "" || c.RefreshToken != "" || len(c.Headers) > 0)
+}
+
+// clientOptions returns the OCI client options carrying the credentials.
+func (c *Credentials) clientOptions() []container.ClientOption {
+	var opts []container.ClientOption
+
+	if c.Username != "" || c.Password != "" {
+		opts = append(opts, container.WithCredential(c.Username, c.Password))
+	}
+
+	if c.BearerToken != "" {
+		opts = append(opts, container.WithAccessToken(c.BearerToken))
+	}
+
+	if c.RefreshToken != "" {
+		opts = append(opts, container.WithRefreshToken(c.RefreshToken))
+	}
+
+	for k, v := range c.Headers {
+		opts = append(opts, container.WithHeader(k, v))
+	}
+
+	return opts
+}
+
+// TLSConfig holds the TLS settings used when talking to a repository or an
+// OCI registry. File paths are read when the HTTP client is built.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of CAs trusted in addition to the system pool.
+	CAFile string
+
+	// CertFile and KeyFile are the PEM client certificate and key presented
+	// to servers that require mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// ServerName overrides the host name used to verify the server
+	// certificate and sent as SNI.
+	ServerName string
+
+	InsecureSkipVerify bool
+}
+
+// Mirror rewrites repository URLs starting with Prefix so that they start with
+// Replacement instead, e.g. to fetch a public repository through an internal
+// mirror.
+type Mirror struct {
+	Prefix      string
+	Replacement string
 }
 
 // Request describes a chart to locate along with the infrastructure paths
@@ -52,7 +120,82 @@ type Request struct {
 	// Nil means no authentication.
 	Credentials func(context.Context) (*Credentials, error)
 
+	// CredentialProviders are asked in order for credentials by host when
+	// neither Credentials nor the repositories file provide any. Mirrored
+	// repositories are looked up by the mirror host.
+	CredentialProviders []CredentialProvider
+
+	// RepositoryConfig is the path to Helm's repositories.yaml. It is used to
+	// resolve "repo/chart" names and to pick up per-repository credentials
+	// and TLS settings. A missing file is treated as an empty one.
+	RepositoryConfig string
+
 	RepositoryCache string
+
+	// TLS configures TLS for repository and registry connections. When set,
+	// it takes precedence over the TLS settings of the repositories file.
+	TLS *TLSConfig
+
+	// HTTPClient, when set, is used for every repository and registry request.
+	// Transport is used instead to build the client when HTTPClient is nil.
+	// Either one replaces the TLS settings, which can then be configured on
+	// the client or transport itself.
+	HTTPClient *http.Client
+	Transport  http.RoundTripper
+
+	// PlainHTTP talks to OCI registries over HTTP instead of HTTPS. Registries
+	// on "localhost" or a loopback address fall back to HTTP automatically.
+	PlainHTTP bool
+
+	// Registries holds per-host plain HTTP and insecure settings for OCI
+	// registries. Every entry matching the registry host applies.
+	Registries []Registry
+
+	// RequireDigest refuses repository index entries that carry no digest.
+	// Digests that are present are always verified.
+	RequireDigest bool
+
+	// Digest is the archive digest the chart is expected to have, for example
+	// one recorded in a lockfile. OCI charts whose chart layer has another
+	// digest fail with a *DigestMismatchError before the layer is downloaded.
+	Digest string
+
+	// Verify requires the chart to carry a provenance file signed by a key in
+	// Keyring: "<archive URL>.prov" for repositories, the provenance layer for
+	// OCI, and "<path>.prov" next to local archives. The signer is reported
+	// in Result.Provenance.
+	Verify bool
+
+	// Keyring is the path to the OpenPGP public keyring used when Verify is set.
+	Keyring string
+
+	// Mirrors rewrite repository index and archive URLs. The first mirror
+	// whose Prefix matches a URL is applied.
+	Mirrors []Mirror
+
+	// Offline restricts resolution to charts already present in
+	// RepositoryCache. No network access is made and Credentials is never
+	// called; missing charts or versions yield a *NotCachedError.
+	Offline bool
+
+	// Locators build the locator for references with a custom scheme, keyed
+	// by lower-cased scheme such as "s3". The scheme of RepoURL is used when
+	// it is set, that of Name otherwise. Registered schemes take precedence
+	// over the built-in "oci", "http" and "https" handling.
+	Locators map[string]LocatorFactory
+
+	// Getters fetch repository indexes and archives whose URLs have a scheme
+	// the HTTP client does not speak, keyed by lower-cased scheme. See
+	// LoadPluginGetters for Helm downloader plugins.
+	Getters map[string]Getter
+
+	// Retry retries transient download failures. Nil disables retries.
+	Retry *RetryPolicy
+
+	// ResolutionPolicy decides which versions are eligible when Version is
+	// empty or a constraint. The selected version and the reason for picking
+	// it are reported in Result.
+	ResolutionPolicy ResolutionPolicy
 }
 
 func (r *Request) resolveCredentials(ctx context.Context) (*Credentials, error) {
@@ -73,7 +216,13 @@ func (r *Request) resolveCredentials(ctx context.Context) (*Credentials, error)
 // Resolution order:
 //  1. When no RepoURL is set, check if Name is a local path.
 //  2. If the path is absolute or starts with '.', error when it does not exist.
-//  3. Otherwise download via the appropriate locator (Repo or OCI).
+//  3. When no RepoURL is set and Name has the form "repo/chart", resolve the
+//     repository alias through RepositoryConfig.
+//  4. Use the locator registered in Locators for the scheme of the reference.
+//  5. Otherwise download via the appropriate locator (Git for "git+" refs,
+//     Archive for http(s) URLs ending in .tgz or .tar.gz, OCI or Repo), or
+//     with Offline set, resolve the chart from RepositoryCache only.
+//     Transient failures are retried according to Retry.
 func Locate(ctx context.Context, req *Request) (Result, error) {
 	if req == nil {
 		return Result{}, ErrNilRequest
@@ -84,7 +233,9 @@ func Locate(ctx context.Context, req *Request) (Result, error) {
 		return Result{}, err
 	}
 
-	result, err := l.Locate(ctx)
+	result, err := req.Retry.do(ctx, func() (Result, error) {
+		return l.Locate(ctx)
+	})
 	if err != nil {
 		return Result{}, fmt.Errorf("failed to locate chart %q: %w", req.Name, err)
 	}
@@ -96,9 +247,18 @@ func newLocator(ctx context.Context, req *Request) (Locator, error) {
 	name := strings.TrimSpace(req.Name)
 	version := strings.TrimSpace(req.Version)
 
+	var keyring string
+	if req.Verify {
+		if req.Keyring == "" {
+			return nil, ErrEmptyKeyring
+		}
+
+		keyring = req.Keyring
+	}
+
 	if req.RepoURL == "" {
 		if _, err := os.Stat(name); err == nil {
-			return &Local{Name: name}, nil
+			return &Local{Name: name, Keyring: keyring}, nil
 		}
 
 		if filepath.IsAbs(name) || strings.HasPrefix(name, ".") {
@@ -106,36 +266,111 @@ func newLocator(ctx context.Context, req *Request) (Locator, error) {
 		}
 	}
 
-	creds, err := req.resolveCredentials(ctx)
+	var creds *Credentials
+	if !req.Offline {
+		c, err := req.resolveCredentials(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		creds = c
+	}
+
+	if len(req.Locators) > 0 {
+		l, err := newSchemeLocator(ctx, req, name, version, creds, keyring)
+		if err != nil || l != nil {
+			return l, err
+		}
+	}
+
+	if req.RepoURL == "" && isGitRef(name) {
+		return newGit(ctx, req, name, version, creds, keyring)
+	}
+
+	if req.RepoURL == "" && isArchiveURL(name) {
+		return newArchive(ctx, req, name, version, creds, keyring)
+	}
+
+	if strings.HasPrefix(name, "oci://") {
+		return newOCI(ctx, req, name, version, creds, keyring)
+	}
+
+	return newRepo(ctx, req, name, version, creds, keyring)
+}
+
+func newRepo(
+	ctx context.Context,
+	req *Request,
+	name string,
+	version string,
+	creds *Credentials,
+	keyring string,
+) (*Repo, error) {
+	repo := &Repo{
+		Name:          name,
+		RepoURL:       req.RepoURL,
+		Version:       version,
+		Credentials:   creds,
+		CacheDir:      req.RepositoryCache,
+		RequireDigest: req.RequireDigest,
+		Offline:       req.Offline,
+		Keyring:       keyring,
+		Mirrors:       req.Mirrors,
+		Policy:        req.ResolutionPolicy,
+		TLS:           req.TLS,
+		HTTPClient:    req.HTTPClient,
+		Transport:     req.Transport,
+		Getters:       req.Getters,
+	}
+
+	entry, err := findRepoEntry(req.RepositoryConfig, req.RepoURL, name)
 	if err != nil {
 		return nil, err
 	}
 
-	var locator Locator
-	if strings.HasPrefix(name, "oci://") {
-		locator = &OCI{
-			Ref:         name,
-			Version:     version,
-			Credentials: creds,
-			CacheDir:    req.RepositoryCache,
+	if entry != nil {
+		if req.RepoURL == "" {
+			_, repo.Name, _ = strings.Cut(name, "/")
+			repo.RepoURL = entry.URL
 		}
-	} else {
-		locator = &Repo{
-			Name:        name,
-			RepoURL:     req.RepoURL,
-			Version:     version,
-			Credentials: creds,
-			CacheDir:    req.RepositoryCache,
+
+		if !creds.hasAuth() && (entry.Username != "" || entry.Password != "") {
+			repo.Credentials = &Credentials{Username: entry.Username, Password: entry.Password}
+		}
+
+		repo.PassCredentialsAll = entry.PassCredentialsAll
+
+		if repo.TLS == nil {
+			repo.TLS = entry.tlsConfig()
+		}
+	}
+
+	// Credentials of the repository stay with its origin; a mirror on another
+	// origin gets its own from the providers.
+	if !repo.PassCredentialsAll && mirroredOrigin(req.Mirrors, repo.RepoURL) {
+		repo.Credentials = nil
+	}
+
+	if !req.Offline && !repo.Credentials.hasAuth() {
+		host := urlHost(rewriteURL(req.Mirrors, repo.RepoURL))
+
+		repo.Credentials, err = providerCredentials(ctx, req.CredentialProviders, host)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return locator, nil
+	return repo, nil
 }
 
 // Local resolves chart references that point to the local filesystem.
 type Local struct {
 	Name      string
 	MustExist bool
+
+	// Keyring, when set, requires a "<path>.prov" provenance file signed by a
+	// key in this OpenPGP keyring. Unpacked chart directories cannot be verified.
+	Keyring string
 }
 
 // Locate returns the absolute path to a local chart, or an error if MustExist
@@ -150,5 +385,23 @@ func (l *Local) Locate(_ context.Context) (Result, error) {
 		return Result{}, fmt.Errorf("unable to resolve absolute path for %q: %w", l.Name, err)
 	}
 
-	return Result{Path: abs, SourceType: SourceLocal}, nil
+	result := Result{Path: abs, SourceType: SourceLocal}
+
+	if info, err := os.Stat(abs); err == nil && info.Mode().IsRegular() {
+		result.Digest, err = fileDigest(abs)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	if l.Keyring != "" {
+		result.Provenance, err = verifyChart(l.Keyring, abs, filepath.Base(abs), nil)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	result.readMetadata()
+
+	return result, nil
 }
@@ -0,0 +1,295 @@
+package locator
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// ErrEmptyArchiveURL is returned when an Archive locator is created without a URL.
+var ErrEmptyArchiveURL = errors.New("archive URL must not be empty")
+
+// Archive resolves charts published as a single archive at an HTTP or HTTPS
+// URL, such as a release asset, without a repository index. The downloaded
+// archive is cached under its URL and reused by later calls. Archives served
+// with an ETag or Last-Modified header are revalidated with a conditional
+// request; archives served without either are treated as immutable.
+type Archive struct {
+	URL         string
+	Version     string
+	Credentials *Credentials
+	CacheDir    string
+	HTTPClient  *http.Client
+
+	// Transport, when set and HTTPClient is nil, is used as the transport of
+	// the HTTP client instead of one built from TLS.
+	Transport http.RoundTripper
+
+	// TLS configures the HTTP client built when neither HTTPClient nor
+	// Transport is set.
+	TLS *TLSConfig
+
+	// Offline resolves the chart from CacheDir only. An archive that was
+	// never downloaded yields a *NotCachedError.
+	Offline bool
+
+	// Keyring, when set, requires a "<URL>.prov" provenance file signed by a
+	// key in this OpenPGP keyring.
+	Keyring string
+
+	// Mirrors rewrite the archive URL before it is fetched.
+	Mirrors []Mirror
+
+	// Policy applies to the version of the downloaded chart. Version, when
+	// set, must match it exactly or as a constraint.
+	Policy ResolutionPolicy
+}
+
+// Locate returns the cached archive for URL, downloading it first when it is
+// not cached yet, and checks its version against Version and Policy.
+func (a *Archive) Locate(ctx context.Context) (Result, error) {
+	if a.URL == "" {
+		return Result{}, ErrEmptyArchiveURL
+	}
+
+	if a.CacheDir == "" {
+		return Result{}, ErrEmptyCacheDir
+	}
+
+	policy, err := a.Policy.compile()
+	if err != nil {
+		return Result{}, err
+	}
+
+	repo := &Repo{
+		RepoURL:     a.URL,
+		Credentials: a.Credentials,
+		CacheDir:    a.CacheDir,
+		HTTPClient:  a.HTTPClient,
+		Transport:   a.Transport,
+		TLS:         a.TLS,
+		Mirrors:     a.Mirrors,
+	}
+
+	chartURL := rewriteURL(a.Mirrors, a.URL)
+
+	result, entry, err := a.cached()
+	if err != nil {
+		return Result{}, err
+	}
+
+	var fetched *catalogEntry
+
+	switch {
+	case entry == nil && a.Offline:
+		return Result{}, &NotCachedError{Ref: a.URL, Version: a.Version}
+	case entry == nil || (!a.Offline && entry.hasValidators()):
+		var path string
+
+		path, fetched, err = a.download(ctx, repo, chartURL, entry)
+		if err != nil {
+			return Result{}, err
+		}
+
+		if fetched != nil {
+			result.Path, result.Digest = path, fetched.Digest
+		}
+	}
+
+	result.SourceType = SourceURL
+	result.URL = chartURL
+
+	meta, err := chartMetadata(result.Path)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to read chart from %q: %w", a.URL, err)
+	}
+
+	result.Metadata = &meta
+	result.Version = meta.Version
+
+	result.Reason, err = checkChartVersion(a.URL, a.Version, meta.Version, policy)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if a.Keyring != "" {
+		var fetchProv func() ([]byte, error)
+		if !a.Offline {
+			fetchProv = func() ([]byte, error) {
+				client, err := repo.client()
+				if err != nil {
+					return nil, err
+				}
+
+				return repo.fetchProvenance(ctx, client, []string{chartURL})
+			}
+		}
+
+		result.Provenance, err = verifyChart(a.Keyring, result.Path, archiveFileName(a.URL), fetchProv)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	if fetched != nil {
+		fetched.Version = meta.Version
+		if err := recordChart(a.CacheDir, a.URL, *fetched); err != nil {
+			return Result{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// download fetches the archive at chartURL into the cache and returns its
+// path and catalog entry, without a version yet. A cached entry with
+// validators is revalidated with If-None-Match / If-Modified-Since; a nil
+// entry is returned when the server answers 304 Not Modified.
+func (a *Archive) download(
+	ctx context.Context,
+	repo *Repo,
+	chartURL string,
+	cached *catalogEntry,
+) (string, *catalogEntry, error) {
+	client, err := repo.client()
+	if err != nil {
+		return "", nil, err
+	}
+
+	creds, err := repo.downloadCredentials(chartURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	header := http.Header{}
+	if cached != nil {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := httpFetch(ctx, client, chartURL, creds, header)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to download chart: %w", err)
+	}
+
+	if resp.NotModified {
+		return "", nil, nil
+	}
+
+	path, digest, err := cacheChart(a.CacheDir, resp.Data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return path, &catalogEntry{
+		Digest:       digest,
+		URL:          chartURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// cached returns the archive recorded for URL in the cache catalog along
+// with its entry, which is nil when the archive is not cached. The most
+// recently recorded entry wins should the URL have been republished with
+// another version.
+func (a *Archive) cached() (Result, *catalogEntry, error) {
+	entries, err := readCatalog(a.CacheDir, a.URL)
+	if err != nil {
+		return Result{}, nil, err
+	}
+
+	if len(entries) == 0 {
+		return Result{}, nil, nil
+	}
+
+	entry := entries[len(entries)-1]
+
+	path := cachedChartPath(a.CacheDir, entry.Digest)
+	if !fileExists(path) {
+		return Result{}, nil, nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Result{}, nil, fmt.Errorf("unable to resolve absolute path for %q: %w", path, err)
+	}
+
+	return Result{Path: abs, Digest: entry.Digest}, &entry, nil
+}
+
+// checkChartVersion matches the version of the chart at ref against the
+// requested version and the policy. An empty request accepts the chart
+// version as is, subject to the minimum version.
+func checkChartVersion(ref string, requested string, version string, policy versionPolicy) (ResolutionReason, error) {
+	idx := repoIndex{Entries: map[string]repoChartVersions{ref: {{Version: version}}}}
+
+	_, reason, err := idx.resolve(ref, cmp.Or(requested, version), policy)
+	if err != nil {
+		return "", fmt.Errorf("chart at %q has version %q: %w", ref, version, err)
+	}
+
+	return reason, nil
+}
+
+// isArchiveURL reports whether ref is an HTTP or HTTPS URL of a chart archive.
+func isArchiveURL(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	p := strings.ToLower(u.Path)
+
+	return strings.HasSuffix(p, ".tgz") || strings.HasSuffix(p, ".tar.gz")
+}
+
+func newArchive(
+	ctx context.Context,
+	req *Request,
+	name string,
+	version string,
+	creds *Credentials,
+	keyring string,
+) (*Archive, error) {
+	archive := &Archive{
+		URL:         name,
+		Version:     version,
+		Credentials: creds,
+		CacheDir:    req.RepositoryCache,
+		HTTPClient:  req.HTTPClient,
+		Transport:   req.Transport,
+		TLS:         req.TLS,
+		Offline:     req.Offline,
+		Keyring:     keyring,
+		Mirrors:     req.Mirrors,
+		Policy:      req.ResolutionPolicy,
+	}
+
+	// Credentials of the archive URL stay with its origin; a mirror on another
+	// origin gets its own from the providers.
+	if mirroredOrigin(req.Mirrors, name) {
+		archive.Credentials = nil
+	}
+
+	if !req.Offline && !archive.Credentials.hasAuth() {
+		c, err := providerCredentials(ctx, req.CredentialProviders, urlHost(rewriteURL(req.Mirrors, name)))
+		if err != nil {
+			return nil, err
+		}
+
+		archive.Credentials = c
+	}
+
+	return archive, nil
+}
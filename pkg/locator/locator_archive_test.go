@@ -0,0 +1,289 @@
+package locator_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+const archivePath = "/releases/download/v1.2.3/mychart-1.2.3.tgz"
+
+func TestLocate_ArchiveURL(t *testing.T) {
+	t.Parallel()
+
+	archive := readChartArchive(t, "mychart", "1.2.3")
+
+	t.Run("should download the archive and reuse the cached copy", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newArchiveServer(t, archive, nil)
+		cacheDir := t.TempDir()
+		req := &locator.Request{
+			Name:            srv.URL + archivePath,
+			RepositoryCache: cacheDir,
+		}
+
+		result, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.SourceType).To(Equal(locator.SourceURL))
+		g.Expect(result.Path).To(BeARegularFile())
+		g.Expect(result.URL).To(Equal(srv.URL + archivePath))
+		g.Expect(result.Version).To(Equal("1.2.3"))
+		g.Expect(result.Reason).To(Equal(locator.ReasonExact))
+		g.Expect(result.Digest).To(HavePrefix("sha256:"))
+		g.Expect(result.Metadata.Name).To(Equal("mychart"))
+
+		again, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(again).To(Equal(result))
+		g.Expect(requests.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("should revalidate cached archives served with an ETag", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var (
+			published   atomic.Bool
+			notModified atomic.Int32
+		)
+
+		republished := readChartArchive(t, "mychart", "1.2.4")
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, etag := archive, `"v1"`
+			if published.Load() {
+				data, etag = republished, `"v2"`
+			}
+
+			if r.Header.Get("If-None-Match") == etag {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+
+				return
+			}
+
+			w.Header().Set("ETag", etag)
+			_, _ = w.Write(data)
+		}))
+		t.Cleanup(srv.Close)
+
+		req := &locator.Request{
+			Name:            srv.URL + archivePath,
+			RepositoryCache: t.TempDir(),
+		}
+
+		result, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Version).To(Equal("1.2.3"))
+
+		again, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(again).To(Equal(result))
+		g.Expect(notModified.Load()).To(Equal(int32(1)))
+
+		published.Store(true)
+
+		updated, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(updated.Version).To(Equal("1.2.4"))
+		g.Expect(updated.Digest).ToNot(Equal(result.Digest))
+
+		req.Offline = true
+
+		offline, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(offline.Version).To(Equal("1.2.4"))
+	})
+
+	t.Run("should resolve from the cache only when offline", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newArchiveServer(t, archive, nil)
+		req := &locator.Request{
+			Name:            srv.URL + archivePath,
+			RepositoryCache: t.TempDir(),
+			Offline:         true,
+		}
+
+		_, err := locator.Locate(t.Context(), req)
+		g.Expect(locator.IsNotCachedError(err)).To(BeTrue())
+		g.Expect(requests.Load()).To(BeZero())
+
+		req.Offline = false
+
+		_, err = locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		srv.Close()
+		req.Offline = true
+
+		result, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Version).To(Equal("1.2.3"))
+	})
+
+	t.Run("should check the chart version against the requested one", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newArchiveServer(t, archive, nil)
+		cacheDir := t.TempDir()
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            srv.URL + archivePath,
+			Version:         "~1.2",
+			RepositoryCache: cacheDir,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Reason).To(Equal(locator.ReasonConstraint))
+
+		_, err = locator.Locate(t.Context(), &locator.Request{
+			Name:            srv.URL + archivePath,
+			Version:         "2.0.0",
+			RepositoryCache: cacheDir,
+		})
+		g.Expect(errors.Is(err, locator.ErrVersionNotFound)).To(BeTrue())
+
+		_, err = locator.Locate(t.Context(), &locator.Request{
+			Name:             srv.URL + archivePath,
+			RepositoryCache:  cacheDir,
+			ResolutionPolicy: locator.ResolutionPolicy{MinVersion: "2.0.0"},
+		})
+		g.Expect(errors.Is(err, locator.ErrVersionBelowMinimum)).To(BeTrue())
+	})
+
+	t.Run("should authenticate to the archive origin only", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var assetAuth atomic.Value
+
+		assets := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assetAuth.Store(r.Header.Get("Authorization"))
+			_, _ = w.Write(archive)
+		}))
+		t.Cleanup(assets.Close)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			http.Redirect(w, r, assets.URL+r.URL.Path, http.StatusFound)
+		}))
+		t.Cleanup(srv.Close)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            srv.URL + archivePath,
+			RepositoryCache: t.TempDir(),
+			CredentialProviders: []locator.CredentialProvider{
+				locator.CredentialProviderFunc(func(_ context.Context, _ string) (*locator.Credentials, error) {
+					return &locator.Credentials{BearerToken: "token"}, nil
+				}),
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(assetAuth.Load()).To(BeEmpty())
+	})
+
+	t.Run("should verify the provenance file next to the archive", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		signer := newTestSigner(t, "Chart Signer", "signer@example.com")
+		prov := signProvenance(t, signer, "mychart-1.2.3.tgz", archive)
+
+		srv, _ := newArchiveServer(t, archive, prov)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            srv.URL + archivePath,
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         writeKeyring(t, signer),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Provenance).ToNot(BeNil())
+		g.Expect(result.Provenance.SignedBy).To(Equal("Chart Signer <signer@example.com>"))
+	})
+
+	t.Run("should report a missing provenance file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		signer := newTestSigner(t, "Chart Signer", "signer@example.com")
+		srv, _ := newArchiveServer(t, archive, nil)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            srv.URL + archivePath,
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         writeKeyring(t, signer),
+		})
+		g.Expect(errors.Is(err, locator.ErrProvenanceNotFound)).To(BeTrue())
+	})
+
+	t.Run("should reject archives that are not charts", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newArchiveServer(t, []byte(chartData), nil)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            srv.URL + archivePath,
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(err).To(MatchError(ContainSubstring("unable to read chart from")))
+	})
+
+	t.Run("should fetch the archive through a mirror", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newArchiveServer(t, archive, nil)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "https://github.example.com" + archivePath,
+			RepositoryCache: t.TempDir(),
+			Mirrors:         []locator.Mirror{{Prefix: "https://github.example.com", Replacement: srv.URL}},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.URL).To(Equal(srv.URL + archivePath))
+		g.Expect(requests.Load()).To(Equal(int32(1)))
+	})
+}
+
+// newArchiveServer serves archive at archivePath and prov next to it, or 404
+// when prov is nil. It returns the server and the number of archive requests.
+func newArchiveServer(t *testing.T, archive []byte, prov []byte) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == archivePath:
+			requests.Add(1)
+			_, _ = w.Write(archive)
+		case strings.HasSuffix(r.URL.Path, ".prov") && prov != nil:
+			_, _ = w.Write(prov)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
@@ -0,0 +1,226 @@
+package locator
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/internal/fsutil"
+)
+
+const catalogDir = "catalog"
+
+// NotCachedError is returned in offline mode when a chart, or a version
+// satisfying the requested constraint, is not present in the local cache.
+type NotCachedError struct {
+	Ref     string
+	Version string
+}
+
+func (e *NotCachedError) Error() string {
+	if e.Version == "" {
+		return fmt.Sprintf("chart %q is not available in the local cache", e.Ref)
+	}
+
+	return fmt.Sprintf("chart %q version %q is not available in the local cache", e.Ref, e.Version)
+}
+
+// IsNotCachedError reports whether err or any error in its chain is a *NotCachedError.
+func IsNotCachedError(err error) bool {
+	var target *NotCachedError
+
+	return errors.As(err, &target)
+}
+
+// catalogEntry records a chart archive stored in a cache directory. Archives
+// are stored under their content digest, so the catalog is what allows a
+// chart to be found again by reference and version.
+//
+// Every entry is a file of its own, "catalog/<sha256(ref)>/<sha256(version)>.json",
+// written by atomic rename. Processes sharing a cache directory therefore
+// never read-modify-write a shared file and cannot drop each other's entries.
+type catalogEntry struct {
+	Version    string `json:"version"`
+	Digest     string `json:"digest"`
+	URL        string `json:"url,omitempty"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+
+	// ETag and LastModified are the validators an archive URL was served
+	// with, used to revalidate the cached archive.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func (e *catalogEntry) hasValidators() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+func repoCatalogKey(repoURL string, name string) string {
+	return strings.TrimSuffix(repoURL, "/") + "/" + name
+}
+
+// catalogKeyDir returns the directory holding the catalog entries of key.
+func catalogKeyDir(cacheDir string, key string) string {
+	return filepath.Join(cacheDir, catalogDir, fmt.Sprintf("%x", sha256.Sum256([]byte(key))))
+}
+
+// readCatalog returns the catalog entries recorded for key, oldest first.
+func readCatalog(cacheDir string, key string) ([]catalogEntry, error) {
+	dir := catalogKeyDir(cacheDir, key)
+
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read chart catalog: %w", err)
+	}
+
+	type recorded struct {
+		entry catalogEntry
+		at    time.Time
+	}
+
+	entries := make([]recorded, 0, len(files))
+
+	for _, f := range files {
+		// Skip the temporary files of writes in progress
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+
+		info, err := f.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read chart catalog entry %q: %w", path, err)
+		}
+
+		data, err := os.ReadFile(path) //nolint:gosec // path is derived from the cache directory
+		if err != nil {
+			return nil, fmt.Errorf("unable to read chart catalog entry %q: %w", path, err)
+		}
+
+		var entry catalogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("unable to parse chart catalog entry %q: %w", path, err)
+		}
+
+		entries = append(entries, recorded{entry: entry, at: info.ModTime()})
+	}
+
+	sort.SliceStable(entries, func(i int, j int) bool {
+		return entries[i].at.Before(entries[j].at)
+	})
+
+	result := make([]catalogEntry, len(entries))
+	for i := range entries {
+		result[i] = entries[i].entry
+	}
+
+	return result, nil
+}
+
+// recordChart adds or updates the catalog entry for key and entry.Version.
+func recordChart(cacheDir string, key string, entry catalogEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode chart catalog entry: %w", err)
+	}
+
+	name := fmt.Sprintf("%x.json", sha256.Sum256([]byte(entry.Version)))
+
+	if err := fsutil.WriteFileAtomic(filepath.Join(catalogKeyDir(cacheDir, key), name), data); err != nil {
+		return fmt.Errorf("unable to write chart catalog entry: %w", err)
+	}
+
+	return nil
+}
+
+// locateCached resolves key and version against the catalog and returns the
+// cached archive without any network access, applying the same version policy
+// as online resolution. With a keyring, the archive is verified against the
+// provenance file cached next to it.
+func locateCached(
+	cacheDir string,
+	key string,
+	version string,
+	sourceType SourceType,
+	keyring string,
+	policy versionPolicy,
+) (Result, error) {
+	entries, err := readCatalog(cacheDir, key)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(entries) == 0 {
+		return Result{}, &NotCachedError{Ref: key, Version: version}
+	}
+
+	versions := make(repoChartVersions, 0, len(entries))
+	byVersion := make(map[string]catalogEntry, len(entries))
+
+	for _, e := range entries {
+		versions = append(versions, repoChartVersion{Version: e.Version, Deprecated: e.Deprecated})
+		byVersion[e.Version] = e
+	}
+
+	sort.Sort(versions)
+
+	idx := repoIndex{Entries: map[string]repoChartVersions{key: versions}}
+
+	cv, reason, err := idx.resolve(key, version, policy)
+	switch {
+	case errors.Is(err, ErrVersionBelowMinimum):
+		return Result{}, err
+	case err != nil:
+		return Result{}, &NotCachedError{Ref: key, Version: version}
+	}
+
+	entry := byVersion[cv.Version]
+
+	path := cachedChartPath(cacheDir, entry.Digest)
+	if !fileExists(path) {
+		return Result{}, &NotCachedError{Ref: key, Version: cv.Version}
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to resolve absolute path for %q: %w", path, err)
+	}
+
+	result := Result{
+		Path:       abs,
+		SourceType: sourceType,
+		Version:    cv.Version,
+		Reason:     reason,
+		Digest:     entry.Digest,
+		URL:        entry.URL,
+	}
+
+	if keyring != "" {
+		fileName := archiveFileName(entry.URL)
+		if sourceType == SourceOCI {
+			fileName = ociArchiveFileName(key, cv.Version, abs)
+		}
+
+		result.Provenance, err = verifyChart(keyring, abs, fileName, nil)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	result.readMetadata()
+
+	return result, nil
+}
@@ -0,0 +1,239 @@
+package locator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+func TestLocate_Offline(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should resolve a previously downloaded repo chart without network", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _, _ := newValidatingChartServer(t, "", "")
+		cacheDir := t.TempDir()
+		repoURL := srv.URL
+
+		online, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         repoURL,
+			Version:         "1.2.3",
+			RepositoryCache: cacheDir,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		srv.Close()
+
+		offline, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         repoURL,
+			Version:         "^1.0.0",
+			RepositoryCache: cacheDir,
+			Offline:         true,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(offline).To(MatchFields(IgnoreExtras, Fields{
+			"Path":       Equal(online.Path),
+			"SourceType": Equal(locator.SourceRepo),
+		}))
+	})
+
+	t.Run("should pick the newest cached version matching the constraint", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, downloads, _ := newValidatingChartServer(t, "", "")
+		cacheDir := t.TempDir()
+
+		for _, version := range []string{"1.0.0", "1.2.3", "2.0.0"} {
+			_, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         version,
+				RepositoryCache: cacheDir,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+
+		calls := downloads.Load()
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "~1.0",
+			RepositoryCache: cacheDir,
+			Offline:         true,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(downloads.Load()).To(Equal(calls))
+	})
+
+	t.Run("should keep every version recorded concurrently", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _, _ := newValidatingChartServer(t, "", "")
+		cacheDir := t.TempDir()
+		versions := []string{"1.0.0", "1.2.3", "2.0.0"}
+
+		var wg sync.WaitGroup
+		for _, version := range versions {
+			wg.Go(func() {
+				_, err := locator.Locate(t.Context(), &locator.Request{
+					Name:            "mychart",
+					RepoURL:         srv.URL,
+					Version:         version,
+					RepositoryCache: cacheDir,
+				})
+				g.Expect(err).ToNot(HaveOccurred())
+			})
+		}
+
+		wg.Wait()
+		srv.Close()
+
+		for _, version := range versions {
+			result, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         version,
+				RepositoryCache: cacheDir,
+				Offline:         true,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Version).To(Equal(version))
+		}
+	})
+
+	t.Run("should return NotCachedError for a chart never downloaded", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "https://charts.example.com",
+			Version:         "1.2.3",
+			RepositoryCache: t.TempDir(),
+			Offline:         true,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsNotCachedError(err)).To(BeTrue())
+
+		var notCached *locator.NotCachedError
+		g.Expect(errors.As(err, &notCached)).To(BeTrue())
+		g.Expect(notCached).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Ref":     Equal("https://charts.example.com/mychart"),
+			"Version": Equal("1.2.3"),
+		})))
+	})
+
+	t.Run("should return NotCachedError when no cached version matches", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _, _ := newValidatingChartServer(t, "", "")
+		cacheDir := t.TempDir()
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: cacheDir,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         ">=2.0.0",
+			RepositoryCache: cacheDir,
+			Offline:         true,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsNotCachedError(err)).To(BeTrue())
+	})
+
+	t.Run("should not resolve credentials in offline mode", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		called := false
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "https://charts.example.com",
+			RepositoryCache: t.TempDir(),
+			Offline:         true,
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				called = true
+
+				return nil, errors.New("credentials must not be requested")
+			},
+		})
+		g.Expect(locator.IsNotCachedError(err)).To(BeTrue())
+		g.Expect(called).To(BeFalse())
+	})
+
+	t.Run("should resolve a previously pulled OCI chart without network", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newMockOCIRegistry(t, []byte("offline-oci-chart"))
+		cacheDir := t.TempDir()
+		ref := "oci://" + srv.ref
+
+		online, err := (&locator.OCI{
+			Ref:       ref,
+			Version:   "1.0.0",
+			CacheDir:  cacheDir,
+			PlainHTTP: true,
+		}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		srv.Close()
+
+		offline, err := (&locator.OCI{
+			Ref:      ref + ":1.0.0",
+			CacheDir: cacheDir,
+			Offline:  true,
+		}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(offline.Path).To(Equal(online.Path))
+
+		_, err = (&locator.OCI{
+			Ref:      ref,
+			Version:  "2.0.0",
+			CacheDir: cacheDir,
+			Offline:  true,
+		}).Locate(t.Context())
+		g.Expect(locator.IsNotCachedError(err)).To(BeTrue())
+	})
+
+	t.Run("should resolve a digest-pinned OCI chart without network", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newMockOCIRegistry(t, []byte("offline-digest-chart"))
+		cacheDir := t.TempDir()
+		ref := fmt.Sprintf("oci://%s@%s", srv.ref, srv.manifestDigest)
+
+		online, err := (&locator.OCI{Ref: ref, CacheDir: cacheDir, PlainHTTP: true}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		srv.Close()
+
+		offline, err := (&locator.OCI{Ref: ref, CacheDir: cacheDir, Offline: true}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(offline.Path).To(Equal(online.Path))
+	})
+}
@@ -0,0 +1,225 @@
+package locator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"helm.sh/helm/v4/pkg/helmpath"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// CredentialProvider resolves credentials by host. It is consulted for
+// repositories and registries whose Source carries no credentials and that
+// have none in the repositories file.
+type CredentialProvider interface {
+	// Credentials returns the credentials for host, given as host[:port], or
+	// nil when the provider has none.
+	Credentials(ctx context.Context, host string) (*Credentials, error)
+}
+
+// CredentialProviderFunc adapts a function to a CredentialProvider.
+type CredentialProviderFunc func(ctx context.Context, host string) (*Credentials, error)
+
+// Credentials calls f.
+func (f CredentialProviderFunc) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	return f(ctx, host)
+}
+
+// NetrcProvider reads username and password from a .netrc file. Machines are
+// matched against the host with and without the port, falling back to the
+// "default" entry. A missing file yields no credentials.
+type NetrcProvider struct {
+	Path string
+}
+
+// Credentials returns the login and password of the entry matching host.
+func (p *NetrcProvider) Credentials(_ context.Context, host string) (*Credentials, error) {
+	data, err := os.ReadFile(p.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil //nolint:nilnil // no file means no credentials
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read netrc file %q: %w", p.Path, err)
+	}
+
+	entries := parseNetrc(data)
+
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+
+	for _, name := range []string{host, hostname, ""} {
+		if e, ok := entries[name]; ok {
+			return &Credentials{Username: e.login, Password: e.password}, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil // no matching machine means no credentials
+}
+
+// DockerConfigProvider reads credentials from a Docker config.json, including
+// its credential helpers. Helm's registry/config.json shares the format. A
+// missing file yields no credentials.
+type DockerConfigProvider struct {
+	Path string
+}
+
+// HelmRegistryConfigProvider returns a DockerConfigProvider for the registry
+// config written by "helm registry login".
+func HelmRegistryConfigProvider() *DockerConfigProvider {
+	return &DockerConfigProvider{Path: helmpath.ConfigPath("registry/config.json")}
+}
+
+// Credentials returns the credentials stored for host.
+func (p *DockerConfigProvider) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	store, err := credentials.NewStore(p.Path, credentials.StoreOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to load docker config %q: %w", p.Path, err)
+	}
+
+	cred, err := store.Get(ctx, credentials.ServerAddressFromRegistry(host))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get credentials for %q from %q: %w", host, p.Path, err)
+	}
+
+	creds := &Credentials{
+		Username:     cred.Username,
+		Password:     cred.Password,
+		BearerToken:  cred.AccessToken,
+		RefreshToken: cred.RefreshToken,
+	}
+
+	if !creds.hasAuth() {
+		return nil, nil //nolint:nilnil // nothing stored means no credentials
+	}
+
+	return creds, nil
+}
+
+// providerCredentials asks the providers in order for credentials for host and
+// returns the first ones found.
+func providerCredentials(ctx context.Context, providers []CredentialProvider, host string) (*Credentials, error) {
+	for _, p := range providers {
+		creds, err := p.Credentials(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials for %q: %w", host, err)
+		}
+
+		if creds.hasAuth() {
+			return creds, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil // nil credentials means no authentication
+}
+
+// urlHost returns the host[:port] of rawURL, or "" when it cannot be parsed.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+type netrcEntry struct {
+	login    string
+	password string
+}
+
+// parseNetrc returns the entries of a .netrc file keyed by machine name; the
+// "default" entry has an empty name. The first entry for a machine wins.
+func parseNetrc(data []byte) map[string]netrcEntry {
+	entries := map[string]netrcEntry{}
+	tokens := netrcTokens(data)
+
+	var (
+		name    string
+		current *netrcEntry
+	)
+
+	flush := func() {
+		if _, seen := entries[name]; current != nil && !seen {
+			entries[name] = *current
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		key := tokens[i]
+		if key == "default" {
+			flush()
+
+			name, current = "", &netrcEntry{}
+
+			continue
+		}
+
+		if i+1 == len(tokens) {
+			break
+		}
+
+		i++
+
+		switch {
+		case key == "machine":
+			flush()
+
+			name, current = tokens[i], &netrcEntry{}
+		case key == "login" && current != nil:
+			current.login = tokens[i]
+		case key == "password" && current != nil:
+			current.password = tokens[i]
+		}
+	}
+
+	flush()
+
+	return entries
+}
+
+// netrcTokens splits a .netrc file into tokens, dropping comment lines and
+// macro definitions, which run up to the next blank line.
+func netrcTokens(data []byte) []string {
+	var (
+		tokens  []string
+		inMacro bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if inMacro {
+			inMacro = len(fields) > 0
+
+			continue
+		}
+
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		for _, f := range fields {
+			if f == "macdef" {
+				inMacro = true
+
+				break
+			}
+
+			tokens = append(tokens, f)
+		}
+	}
+
+	return tokens
+}
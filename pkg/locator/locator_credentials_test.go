@@ -0,0 +1,347 @@
+package locator_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+const netrcData = `# build credentials
+machine charts.example.com:8443
+  login port-user
+  password port-pass
+
+machine charts.example.com login host-user password host-pass
+
+macdef init
+machine ignored.example.com login macro-user password macro-pass
+
+machine other.example.com
+  login other-user
+  account unused
+  password other-pass
+
+default login default-user password default-pass
+`
+
+func TestNetrcProvider(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".netrc")
+	if err := os.WriteFile(path, []byte(netrcData), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		host string
+		want *locator.Credentials
+	}{
+		{
+			name: "should match the host and port",
+			host: "charts.example.com:8443",
+			want: &locator.Credentials{Username: "port-user", Password: "port-pass"},
+		},
+		{
+			name: "should match the host name without the port",
+			host: "charts.example.com:9000",
+			want: &locator.Credentials{Username: "host-user", Password: "host-pass"},
+		},
+		{
+			name: "should skip account tokens",
+			host: "other.example.com",
+			want: &locator.Credentials{Username: "other-user", Password: "other-pass"},
+		},
+		{
+			name: "should skip macro definitions",
+			host: "ignored.example.com",
+			want: &locator.Credentials{Username: "default-user", Password: "default-pass"},
+		},
+		{
+			name: "should fall back to the default entry",
+			host: "unknown.example.com",
+			want: &locator.Credentials{Username: "default-user", Password: "default-pass"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			creds, err := (&locator.NetrcProvider{Path: path}).Credentials(t.Context(), tt.host)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(creds).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("should return no credentials for a missing file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		provider := &locator.NetrcProvider{Path: filepath.Join(t.TempDir(), ".netrc")}
+
+		creds, err := provider.Credentials(t.Context(), "charts.example.com")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(creds).To(BeNil())
+	})
+}
+
+func TestDockerConfigProvider(t *testing.T) {
+	t.Parallel()
+
+	basic := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	hub := base64.StdEncoding.EncodeToString([]byte("hub-user:hub-pass"))
+
+	path := writeDockerConfig(t, `{"auths": {
+		"registry.example.com:5000": {"auth": "`+basic+`"},
+		"tokens.example.com": {"identitytoken": "refresh"},
+		"https://index.docker.io/v1/": {"auth": "`+hub+`"}
+	}}`)
+
+	tests := []struct {
+		name string
+		host string
+		want *locator.Credentials
+	}{
+		{
+			name: "should decode basic auth",
+			host: "registry.example.com:5000",
+			want: &locator.Credentials{Username: "user", Password: "pass"},
+		},
+		{
+			name: "should return identity tokens as refresh tokens",
+			host: "tokens.example.com",
+			want: &locator.Credentials{RefreshToken: "refresh"},
+		},
+		{
+			name: "should map docker.io to the Docker Hub entry",
+			host: "docker.io",
+			want: &locator.Credentials{Username: "hub-user", Password: "hub-pass"},
+		},
+		{
+			name: "should return no credentials for unknown hosts",
+			host: "unknown.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			creds, err := (&locator.DockerConfigProvider{Path: path}).Credentials(t.Context(), tt.host)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(creds).To(Equal(tt.want))
+		})
+	}
+
+	t.Run("should return no credentials for a missing file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		provider := &locator.DockerConfigProvider{Path: filepath.Join(t.TempDir(), "config.json")}
+
+		creds, err := provider.Credentials(t.Context(), "registry.example.com")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(creds).To(BeNil())
+	})
+}
+
+func TestLocate_CredentialProviders(t *testing.T) {
+	t.Parallel()
+
+	// newBasicAuthServer serves the chart only with the given basic auth.
+	newBasicAuthServer := func(t *testing.T, username string, password string) *httptest.Server {
+		t.Helper()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			if r.URL.Path == indexPath {
+				_, _ = w.Write([]byte(repoIndexYAML))
+
+				return
+			}
+
+			_, _ = w.Write([]byte(chartData))
+		}))
+		t.Cleanup(srv.Close)
+
+		return srv
+	}
+
+	staticProvider := func(creds *locator.Credentials) locator.CredentialProvider {
+		return locator.CredentialProviderFunc(func(_ context.Context, _ string) (*locator.Credentials, error) {
+			return creds, nil
+		})
+	}
+
+	t.Run("should look up repository credentials by host", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newBasicAuthServer(t, "user", "pass")
+
+		var requested string
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			CredentialProviders: []locator.CredentialProvider{
+				staticProvider(nil),
+				locator.CredentialProviderFunc(func(_ context.Context, host string) (*locator.Credentials, error) {
+					requested = host
+
+					return &locator.Credentials{Username: "user", Password: "pass"}, nil
+				}),
+				staticProvider(&locator.Credentials{Username: "later", Password: "later"}),
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(requested).To(Equal(srv.Listener.Addr().String()))
+	})
+
+	t.Run("should prefer source credentials", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newBasicAuthServer(t, "user", "pass")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{Username: "user", Password: "pass"}, nil
+			},
+			CredentialProviders: []locator.CredentialProvider{
+				staticProvider(&locator.Credentials{Username: "other", Password: "other"}),
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should prefer credentials from the repositories file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newBasicAuthServer(t, "user", "pass")
+		config := writeRepositoriesFile(t, `repositories:
+  - name: myrepo
+    url: `+srv.URL+`
+    username: user
+    password: pass
+`)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			Version:          "1.0.0",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+			CredentialProviders: []locator.CredentialProvider{
+				staticProvider(&locator.Credentials{Username: "other", Password: "other"}),
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should look up registry credentials by host", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		registry := newMockOCIRegistry(t, []byte("provider-oci-chart"))
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			registry.Config.Handler.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+
+		host := srv.Listener.Addr().String()
+		config := writeDockerConfig(t, `{"auths": {"`+host+`": {"auth": "`+
+			base64.StdEncoding.EncodeToString([]byte("user:pass"))+`"}}}`)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:                "oci://" + host + "/test/chart",
+			Version:             "1.0.0",
+			RepositoryCache:     t.TempDir(),
+			PlainHTTP:           true,
+			CredentialProviders: []locator.CredentialProvider{&locator.DockerConfigProvider{Path: config}},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should report provider errors", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		errBoom := errors.New("boom")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "https://charts.example.com",
+			RepositoryCache: t.TempDir(),
+			CredentialProviders: []locator.CredentialProvider{
+				locator.CredentialProviderFunc(func(_ context.Context, _ string) (*locator.Credentials, error) {
+					return nil, errBoom
+				}),
+			},
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, errBoom)).To(BeTrue())
+	})
+
+	t.Run("should not consult providers offline", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "https://charts.example.com",
+			RepositoryCache: t.TempDir(),
+			Offline:         true,
+			CredentialProviders: []locator.CredentialProvider{
+				locator.CredentialProviderFunc(func(_ context.Context, _ string) (*locator.Credentials, error) {
+					t.Error("provider called in offline mode")
+
+					return nil, nil //nolint:nilnil // no credentials
+				}),
+			},
+		})
+		g.Expect(locator.IsNotCachedError(err)).To(BeTrue())
+	})
+}
+
+func writeDockerConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
@@ -0,0 +1,250 @@
+package locator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v4/pkg/helmpath"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrEmptyGetterCommand is returned when a PluginGetter has no command.
+var ErrEmptyGetterCommand = errors.New("getter command must not be empty")
+
+// Getter fetches repository indexes, chart archives and provenance files for
+// a URL scheme the HTTP client does not speak, e.g. "s3" or "gs". Getters are
+// registered by scheme in Request.Getters and used by the Repo locator for
+// every URL with that scheme.
+type Getter interface {
+	Get(ctx context.Context, req *GetterRequest) ([]byte, error)
+}
+
+// GetterFunc adapts a function to a Getter.
+type GetterFunc func(ctx context.Context, req *GetterRequest) ([]byte, error)
+
+// Get calls f.
+func (f GetterFunc) Get(ctx context.Context, req *GetterRequest) ([]byte, error) {
+	return f(ctx, req)
+}
+
+// GetterRequest describes a single download made through a Getter.
+type GetterRequest struct {
+	URL string
+
+	// Credentials are the repository credentials, subject to the same origin
+	// rules as for HTTP repositories. Nil means none.
+	Credentials *Credentials
+
+	// TLS is the repository TLS configuration, if any.
+	TLS *TLSConfig
+
+	// CacheDir is the repository cache directory.
+	CacheDir string
+}
+
+// PluginGetter runs a Helm downloader plugin. The command is called with the
+// client certificate, key and CA file followed by the URL, and prints the
+// downloaded content to stdout. Credentials are passed in HELM_PLUGIN_USERNAME
+// and HELM_PLUGIN_PASSWORD.
+type PluginGetter struct {
+	// Name and Dir are exported to the command as HELM_PLUGIN_NAME and
+	// HELM_PLUGIN_DIR. A relative command is resolved against Dir.
+	Name string
+	Dir  string
+
+	// Command is the command line, split on spaces after expanding
+	// $HELM_PLUGIN_DIR and $HELM_PLUGIN_NAME.
+	Command string
+
+	// Env holds additional "KEY=value" environment entries.
+	Env []string
+}
+
+// Get runs the plugin command for req.URL and returns its output.
+func (p *PluginGetter) Get(ctx context.Context, req *GetterRequest) ([]byte, error) {
+	args := strings.Fields(os.Expand(p.Command, func(key string) string {
+		switch key {
+		case "HELM_PLUGIN_DIR":
+			return p.Dir
+		case "HELM_PLUGIN_NAME":
+			return p.Name
+		default:
+			return os.Getenv(key)
+		}
+	}))
+	if len(args) == 0 {
+		return nil, ErrEmptyGetterCommand
+	}
+
+	command := args[0]
+	if !filepath.IsAbs(command) && p.Dir != "" {
+		command = filepath.Join(p.Dir, command)
+	}
+
+	var tlsConfig TLSConfig
+	if req.TLS != nil {
+		tlsConfig = *req.TLS
+	}
+
+	var creds Credentials
+	if req.Credentials != nil {
+		creds = *req.Credentials
+	}
+
+	args = append(args[1:], tlsConfig.CertFile, tlsConfig.KeyFile, tlsConfig.CAFile, req.URL)
+
+	cmd := exec.CommandContext(ctx, command, args...) //nolint:gosec // the command is configured by the caller
+	cmd.Env = append(os.Environ(), p.Env...)
+	cmd.Env = append(cmd.Env,
+		"HELM_PLUGIN_NAME="+p.Name,
+		"HELM_PLUGIN_DIR="+p.Dir,
+		"HELM_PLUGIN_USERNAME="+creds.Username,
+		"HELM_PLUGIN_PASSWORD="+creds.Password,
+		"HELM_REPOSITORY_CACHE="+req.CacheDir,
+	)
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("plugin %q failed to get %q: %w: %s",
+			p.Name, req.URL, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// pluginFile is the part of a Helm plugin.yaml describing downloaders.
+type pluginFile struct {
+	Name        string `json:"name"`
+	Downloaders []struct {
+		Command   string   `json:"command"`
+		Protocols []string `json:"protocols"`
+	} `json:"downloaders"`
+}
+
+// LoadPluginGetters returns a PluginGetter for every protocol of every
+// downloader declared by the Helm plugins installed below dir, keyed by
+// scheme. When several plugins claim a scheme, the first in directory order
+// wins. A missing directory yields no getters.
+func LoadPluginGetters(dir string) (map[string]Getter, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil //nolint:nilnil // no plugins means no getters
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read plugin directory %q: %w", dir, err)
+	}
+
+	getters := map[string]Getter{}
+
+	for _, entry := range entries {
+		pluginDir := filepath.Join(dir, entry.Name())
+
+		data, err := os.ReadFile(filepath.Join(pluginDir, "plugin.yaml")) //nolint:gosec // path is below the plugin directory
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read plugin %q: %w", entry.Name(), err)
+		}
+
+		var plugin pluginFile
+		if err := yaml.Unmarshal(data, &plugin); err != nil {
+			return nil, fmt.Errorf("unable to parse plugin %q: %w", entry.Name(), err)
+		}
+
+		for _, d := range plugin.Downloaders {
+			for _, protocol := range d.Protocols {
+				scheme := strings.ToLower(protocol)
+				if _, ok := getters[scheme]; ok {
+					continue
+				}
+
+				getters[scheme] = &PluginGetter{Name: plugin.Name, Dir: pluginDir, Command: d.Command}
+			}
+		}
+	}
+
+	return getters, nil
+}
+
+// HelmPluginGetters loads the downloader plugins installed for the Helm CLI,
+// from $HELM_PLUGINS or Helm's default plugin directory.
+func HelmPluginGetters() (map[string]Getter, error) {
+	dir := os.Getenv("HELM_PLUGINS")
+	if dir == "" {
+		dir = helmpath.DataPath("plugins")
+	}
+
+	return LoadPluginGetters(dir)
+}
+
+// getterTransport serves requests whose scheme has a registered Getter and
+// passes all others to base.
+type getterTransport struct {
+	base    http.RoundTripper
+	getters map[string]Getter
+	repo    *Repo
+}
+
+// withGetters returns a copy of client that routes URLs with a scheme in
+// getters to the respective Getter.
+func withGetters(client *http.Client, getters map[string]Getter, repo *Repo) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	routed := *client
+	routed.Transport = &getterTransport{base: base, getters: getters, repo: repo}
+
+	return &routed
+}
+
+func (t *getterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	getter, ok := t.getters[strings.ToLower(req.URL.Scheme)]
+	if !ok || getter == nil {
+		return t.base.RoundTrip(req) //nolint:wrapcheck // transparent transport
+	}
+
+	creds, err := t.repo.downloadCredentials(req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := getter.Get(req.Context(), &GetterRequest{
+		URL:         req.URL.String(),
+		Credentials: creds,
+		TLS:         t.repo.TLS,
+		CacheDir:    t.repo.CacheDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %q: %w", req.URL, err)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,207 @@
+package locator_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+// pluginScript serves index.yaml and chart.tgz from the plugin directory and
+// logs its arguments and credentials to calls.log.
+const pluginScript = `#!/bin/sh
+echo "$1|$2|$3|$4|$HELM_PLUGIN_NAME|$HELM_PLUGIN_USERNAME:$HELM_PLUGIN_PASSWORD" >> "$HELM_PLUGIN_DIR/calls.log"
+case "$4" in
+  */missing/*) echo "no such bucket" >&2; exit 1 ;;
+  */index.yaml) cat "$HELM_PLUGIN_DIR/index.yaml" ;;
+  *) cat "$HELM_PLUGIN_DIR/chart.tgz" ;;
+esac
+`
+
+const pluginYAML = `name: s3
+version: 0.1.0
+downloaders:
+  - command: bin/getter.sh
+    protocols:
+      - s3
+      - S3A
+`
+
+func TestLocate_Getters(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should fetch index and archive through the getter", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		archive := readChartArchive(t, "mychart", "1.0.0")
+
+		var (
+			mu       sync.Mutex
+			requests []*locator.GetterRequest
+		)
+
+		getter := locator.GetterFunc(func(_ context.Context, req *locator.GetterRequest) ([]byte, error) {
+			mu.Lock()
+			requests = append(requests, req)
+			mu.Unlock()
+
+			if strings.HasSuffix(req.URL, indexPath) {
+				return []byte(repoIndexYAML), nil
+			}
+
+			return archive, nil
+		})
+
+		cacheDir := t.TempDir()
+		tlsConfig := &locator.TLSConfig{ServerName: "bucket.example.com"}
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "s3://bucket/charts",
+			Version:         "1.0.0",
+			RepositoryCache: cacheDir,
+			TLS:             tlsConfig,
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{Username: "user", Password: "pass"}, nil
+			},
+			Getters: map[string]locator.Getter{"s3": getter},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.SourceType).To(Equal(locator.SourceRepo))
+		g.Expect(result.URL).To(Equal("s3://bucket/charts/mychart-1.0.0.tgz"))
+		g.Expect(result.Metadata.Name).To(Equal("mychart"))
+
+		creds := &locator.Credentials{Username: "user", Password: "pass"}
+		g.Expect(requests).To(Equal([]*locator.GetterRequest{
+			{URL: "s3://bucket/charts/index.yaml", Credentials: creds, TLS: tlsConfig, CacheDir: cacheDir},
+			{URL: "s3://bucket/charts/mychart-1.0.0.tgz", Credentials: creds, TLS: tlsConfig, CacheDir: cacheDir},
+		}))
+	})
+
+	t.Run("should report getter errors", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		errBoom := errors.New("boom")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "s3://bucket/charts",
+			RepositoryCache: t.TempDir(),
+			Getters: map[string]locator.Getter{
+				"s3": locator.GetterFunc(func(_ context.Context, _ *locator.GetterRequest) ([]byte, error) {
+					return nil, errBoom
+				}),
+			},
+		})
+		g.Expect(errors.Is(err, errBoom)).To(BeTrue())
+	})
+
+	t.Run("should run Helm downloader plugins", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		pluginsDir := t.TempDir()
+		pluginDir := writeGetterPlugin(t, pluginsDir, readChartArchive(t, "mychart", "1.0.0"))
+
+		getters, err := locator.LoadPluginGetters(pluginsDir)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(getters).To(HaveKey("s3"))
+		g.Expect(getters).To(HaveKey("s3a"))
+
+		// The files are only passed on to the plugin, but must be valid.
+		tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+		t.Cleanup(tlsServer.Close)
+
+		caFile := writeCAFile(t, tlsServer)
+		certFile, keyFile := writeKeyPair(t, tlsServer)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "s3://bucket/charts",
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			TLS:             &locator.TLSConfig{CertFile: certFile, KeyFile: keyFile, CAFile: caFile},
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{Username: "user", Password: "pass"}, nil
+			},
+			Getters: getters,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Metadata.Name).To(Equal("mychart"))
+
+		calls, err := os.ReadFile(filepath.Join(pluginDir, "calls.log"))
+		g.Expect(err).ToNot(HaveOccurred())
+		prefix := certFile + "|" + keyFile + "|" + caFile + "|"
+		g.Expect(strings.Split(strings.TrimSpace(string(calls)), "\n")).To(Equal([]string{
+			prefix + "s3://bucket/charts/index.yaml|s3|user:pass",
+			prefix + "s3://bucket/charts/mychart-1.0.0.tgz|s3|user:pass",
+		}))
+	})
+
+	t.Run("should report plugin failures with their output", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		pluginsDir := t.TempDir()
+		writeGetterPlugin(t, pluginsDir, nil)
+
+		getters, err := locator.LoadPluginGetters(pluginsDir)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "s3://bucket/missing/charts",
+			RepositoryCache: t.TempDir(),
+			Getters:         getters,
+		})
+		g.Expect(err).To(MatchError(ContainSubstring("no such bucket")))
+	})
+
+	t.Run("should load no getters from a missing directory", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		getters, err := locator.LoadPluginGetters(filepath.Join(t.TempDir(), "plugins"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(getters).To(BeEmpty())
+	})
+}
+
+// writeGetterPlugin installs a downloader plugin for "s3" below pluginsDir
+// serving repoIndexYAML and archive, and returns its directory.
+func writeGetterPlugin(t *testing.T, pluginsDir string, archive []byte) string {
+	t.Helper()
+
+	dir := filepath.Join(pluginsDir, "helm-s3")
+
+	files := map[string][]byte{
+		"plugin.yaml":   []byte(pluginYAML),
+		"bin/getter.sh": []byte(pluginScript),
+		"index.yaml":    []byte(repoIndexYAML),
+		"chart.tgz":     archive,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, content, 0700); err != nil { //nolint:gosec // the getter script must be executable
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
@@ -0,0 +1,470 @@
+package locator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/internal/fsutil"
+)
+
+const (
+	gitCacheDir  = "git"
+	gitRefsDir   = "refs"
+	gitRefPrefix = "git+"
+)
+
+var (
+	// ErrInvalidGitRef is returned for malformed "git+<url>//<path>?ref=" references.
+	ErrInvalidGitRef = errors.New("invalid git reference")
+
+	// ErrGitRefNotFound is returned when a branch, tag or ref does not exist
+	// in the remote repository.
+	ErrGitRefNotFound = errors.New("git ref not found")
+)
+
+// gitCommitPattern matches full SHA-1 and SHA-256 commit IDs.
+//
+//nolint:gochecknoglobals // compiled once, read-only
+var gitCommitPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// Git resolves charts stored in a git repository, addressed as
+// "git+https://host/repo//path/to/chart?ref=v1.2.0". The "git+ssh" and
+// "git+file" transports are supported as well. The git command line client
+// must be installed. Checkouts are cached by commit in CacheDir, so a commit
+// is only fetched once and a ref that was resolved before can be used offline.
+type Git struct {
+	// Ref is the "git+" reference of the chart. Without a "ref" query
+	// parameter, the remote HEAD is used.
+	Ref         string
+	Version     string
+	Credentials *Credentials
+	CacheDir    string
+
+	// TLS configures git's HTTPS client through http.sslCAInfo, http.sslCert,
+	// http.sslKey and http.sslVerify.
+	TLS *TLSConfig
+
+	// Offline resolves the ref from CacheDir only. Refs and commits that were
+	// never fetched yield a *NotCachedError.
+	Offline bool
+
+	// Policy applies to the version in the chart's Chart.yaml. Version, when
+	// set, must match it exactly or as a constraint.
+	Policy ResolutionPolicy
+}
+
+// gitRef is a parsed "git+" reference.
+type gitRef struct {
+	// repo is the repository URL without the "git+" prefix.
+	repo string
+
+	// path is the chart directory within the repository, "." for the root.
+	path string
+
+	// ref is the branch, tag, ref or commit to check out.
+	ref string
+}
+
+// parseGitRef splits "git+<repo>//<path>?ref=<ref>" into its parts.
+func parseGitRef(raw string) (gitRef, error) {
+	if !isGitRef(raw) {
+		return gitRef{}, fmt.Errorf("%w: %q must start with %q", ErrInvalidGitRef, raw, gitRefPrefix)
+	}
+
+	u, err := url.Parse(raw[len(gitRefPrefix):])
+	if err != nil {
+		return gitRef{}, fmt.Errorf("%w: %w", ErrInvalidGitRef, err)
+	}
+
+	switch u.Scheme {
+	case "https", "http", "ssh", "file":
+	default:
+		return gitRef{}, fmt.Errorf("%w: unsupported transport %q", ErrInvalidGitRef, u.Scheme)
+	}
+
+	query := u.Query()
+	ref := query.Get("ref")
+	query.Del("ref")
+	u.RawQuery = query.Encode()
+
+	repoPath, chartPath, _ := strings.Cut(u.Path, "//")
+	u.Path = repoPath
+	u.RawPath = ""
+
+	chartPath = path.Clean("/" + chartPath)[1:]
+	if chartPath == "" {
+		chartPath = "."
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		return gitRef{}, fmt.Errorf("%w: %q has no repository path", ErrInvalidGitRef, raw)
+	}
+
+	return gitRef{repo: u.String(), path: chartPath, ref: ref}, nil
+}
+
+// isGitRef reports whether ref uses the "git+" prefix.
+func isGitRef(ref string) bool {
+	return strings.HasPrefix(strings.ToLower(ref), gitRefPrefix)
+}
+
+// Locate resolves the ref to a commit, checks the commit out into the cache
+// unless it is there already and returns the chart directory within it.
+func (g *Git) Locate(ctx context.Context) (Result, error) {
+	if g.CacheDir == "" {
+		return Result{}, ErrEmptyCacheDir
+	}
+
+	ref, err := parseGitRef(g.Ref)
+	if err != nil {
+		return Result{}, err
+	}
+
+	policy, err := g.Policy.compile()
+	if err != nil {
+		return Result{}, err
+	}
+
+	repoDir := g.repoDir(ref.repo)
+
+	commit, err := g.resolveCommit(ctx, ref, repoDir)
+	if err != nil {
+		return Result{}, err
+	}
+
+	checkout := filepath.Join(repoDir, commit)
+	if !fileExists(checkout) {
+		if g.Offline {
+			return Result{}, &NotCachedError{Ref: g.Ref, Version: g.Version}
+		}
+
+		if err := g.checkout(ctx, ref.repo, commit, checkout); err != nil {
+			return Result{}, err
+		}
+	}
+
+	chartDir, err := filepath.Abs(filepath.Join(checkout, filepath.FromSlash(ref.path)))
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to resolve absolute path for %q: %w", ref.path, err)
+	}
+
+	meta, err := chartMetadata(chartDir)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to read chart %q at commit %s: %w", ref.path, commit, err)
+	}
+
+	reason, err := checkChartVersion(g.Ref, g.Version, meta.Version, policy)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Path:       chartDir,
+		SourceType: SourceGit,
+		Version:    meta.Version,
+		Reason:     reason,
+		URL:        gitRefPrefix + ref.repo + "//" + ref.path + "?ref=" + commit,
+		Commit:     commit,
+		Metadata:   &meta,
+	}, nil
+}
+
+// repoDir returns the cache directory holding the checkouts of repo.
+func (g *Git) repoDir(repo string) string {
+	return filepath.Join(g.CacheDir, gitCacheDir, fmt.Sprintf("%x", sha256.Sum256([]byte(repo))))
+}
+
+// resolveCommit returns the commit ref points to. Commit IDs are used as is;
+// other refs are looked up with "git ls-remote" and recorded in the cache, or
+// read from the cache when offline.
+func (g *Git) resolveCommit(ctx context.Context, ref gitRef, repoDir string) (string, error) {
+	if gitCommitPattern.MatchString(ref.ref) {
+		return ref.ref, nil
+	}
+
+	name := ref.ref
+	if name == "" {
+		name = "HEAD"
+	}
+
+	refPath := gitRefPath(repoDir, name)
+
+	if g.Offline {
+		commit, err := readGitRef(refPath)
+		if err != nil {
+			return "", err
+		}
+
+		if commit == "" {
+			return "", &NotCachedError{Ref: g.Ref, Version: g.Version}
+		}
+
+		return commit, nil
+	}
+
+	// Annotated tags are only peeled to their commit when asked for explicitly.
+	out, err := g.run(ctx, "", ref.repo, "ls-remote", ref.repo, name, name+"^{}")
+	if err != nil {
+		return "", err
+	}
+
+	commit, ok := matchGitRef(out, name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q in %s", ErrGitRefNotFound, name, ref.repo)
+	}
+
+	if err := recordGitRef(refPath, name, commit); err != nil {
+		return "", err
+	}
+
+	return commit, nil
+}
+
+// matchGitRef picks the commit of name from "git ls-remote" output. A peeled
+// annotated tag wins over the tag object, tags over branches.
+func matchGitRef(lsRemote []byte, name string) (string, bool) {
+	refs := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(lsRemote))
+	for scanner.Scan() {
+		commit, refName, ok := strings.Cut(scanner.Text(), "\t")
+		if ok {
+			refs[refName] = commit
+		}
+	}
+
+	candidates := []string{name + "^{}", name}
+	if !strings.HasPrefix(name, "refs/") && name != "HEAD" {
+		candidates = []string{"refs/tags/" + name + "^{}", "refs/tags/" + name, "refs/heads/" + name}
+	}
+
+	for _, c := range candidates {
+		if commit, ok := refs[c]; ok {
+			return commit, true
+		}
+	}
+
+	return "", false
+}
+
+// checkout fetches commit from repo and stores its tree, without the .git
+// directory, at dest. The tree is assembled in a temporary directory and
+// renamed into place, so concurrent readers never see a partial checkout.
+func (g *Git) checkout(ctx context.Context, repo string, commit string, dest string) error {
+	parent := filepath.Dir(dest)
+	if err := os.MkdirAll(parent, dirPermissions); err != nil {
+		return fmt.Errorf("unable to create cache directory: %w", err)
+	}
+
+	tmp, err := os.MkdirTemp(parent, "."+commit+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create checkout directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", "--no-tags", repo, commit},
+		{"-c", "advice.detachedHead=false", "checkout", "--quiet", commit},
+	}
+
+	for _, args := range steps {
+		if _, err := g.run(ctx, tmp, repo, args...); err != nil {
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(tmp, ".git")); err != nil {
+		return fmt.Errorf("unable to clean up checkout: %w", err)
+	}
+
+	if err := os.Rename(tmp, dest); err != nil && !fileExists(dest) {
+		return fmt.Errorf("unable to store checkout of %s: %w", commit, err)
+	}
+
+	return nil
+}
+
+// run executes git in dir with the credentials and TLS settings scoped to
+// repo, and returns its standard output.
+func (g *Git) run(ctx context.Context, dir string, repo string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Env = append(cmd.Env, g.configEnv(repo)...)
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// configEnv passes credentials and TLS settings to git through
+// GIT_CONFIG_COUNT, scoped to the origin of repo, so that they stay out of
+// the command line and are not sent to other hosts. Only HTTP(S) remotes
+// use them.
+func (g *Git) configEnv(repo string) []string {
+	u, err := url.Parse(repo)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil
+	}
+
+	prefix := "http." + u.Scheme + "://" + u.Host + "/."
+
+	var config [][2]string
+
+	if creds := g.Credentials; creds.hasAuth() {
+		switch {
+		case creds.BearerToken != "":
+			config = append(config, [2]string{prefix + "extraHeader", "Authorization: Bearer " + creds.BearerToken})
+		case creds.Username != "" || creds.Password != "":
+			basic := base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password))
+			config = append(config, [2]string{prefix + "extraHeader", "Authorization: Basic " + basic})
+		}
+
+		for k, v := range creds.Headers {
+			config = append(config, [2]string{prefix + "extraHeader", k + ": " + v})
+		}
+	}
+
+	if tlsConfig := g.TLS; tlsConfig != nil {
+		for key, value := range map[string]string{
+			"sslCAInfo": tlsConfig.CAFile,
+			"sslCert":   tlsConfig.CertFile,
+			"sslKey":    tlsConfig.KeyFile,
+		} {
+			if value != "" {
+				config = append(config, [2]string{prefix + key, value})
+			}
+		}
+
+		if tlsConfig.InsecureSkipVerify {
+			config = append(config, [2]string{prefix + "sslVerify", "false"})
+		}
+	}
+
+	if len(config) == 0 {
+		return nil
+	}
+
+	env := []string{"GIT_CONFIG_COUNT=" + strconv.Itoa(len(config))}
+	for i, kv := range config {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, kv[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, kv[1]),
+		)
+	}
+
+	return env
+}
+
+// gitRefEntry is the file recording the commit a ref resolved to.
+type gitRefEntry struct {
+	Name   string `json:"name"`
+	Commit string `json:"commit"`
+}
+
+// gitRefPath returns the file recording ref name of the repository cached in
+// repoDir. Every ref is a file of its own written by atomic rename, so that
+// processes sharing a cache directory never overwrite each other's refs.
+func gitRefPath(repoDir string, name string) string {
+	return filepath.Join(repoDir, gitRefsDir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(name))))
+}
+
+// readGitRef returns the commit recorded at path, or "" when none is.
+func readGitRef(path string) (string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is derived from the cache directory
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("unable to read git ref: %w", err)
+	}
+
+	var entry gitRefEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return "", fmt.Errorf("unable to parse git ref %q: %w", path, err)
+	}
+
+	return entry.Commit, nil
+}
+
+// recordGitRef records the commit name resolved to for offline use.
+func recordGitRef(path string, name string, commit string) error {
+	recorded, err := readGitRef(path)
+	if err != nil {
+		return err
+	}
+
+	if recorded == commit {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(gitRefEntry{Name: name, Commit: commit}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode git ref: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, data); err != nil {
+		return fmt.Errorf("unable to write git ref: %w", err)
+	}
+
+	return nil
+}
+
+func newGit(
+	ctx context.Context,
+	req *Request,
+	name string,
+	version string,
+	creds *Credentials,
+	keyring string,
+) (*Git, error) {
+	// Chart directories carry no provenance file to verify.
+	if keyring != "" {
+		return nil, fmt.Errorf("%w: git chart %q cannot be verified", ErrProvenanceNotFound, name)
+	}
+
+	g := &Git{
+		Ref:         name,
+		Version:     version,
+		Credentials: creds,
+		CacheDir:    req.RepositoryCache,
+		TLS:         req.TLS,
+		Offline:     req.Offline,
+		Policy:      req.ResolutionPolicy,
+	}
+
+	if !req.Offline && !creds.hasAuth() {
+		if ref, err := parseGitRef(name); err == nil {
+			g.Credentials, err = providerCredentials(ctx, req.CredentialProviders, urlHost(ref.repo))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return g, nil
+}
@@ -0,0 +1,300 @@
+package locator_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLocate_Git(t *testing.T) {
+	t.Parallel()
+
+	repo := newGitRepo(t)
+
+	t.Run("should check out the chart at a tag", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart?ref=v1.0.0",
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.SourceType).To(Equal(locator.SourceGit))
+		g.Expect(result.Commit).To(Equal(repo.v1))
+		g.Expect(result.Version).To(Equal("1.0.0"))
+		g.Expect(result.Reason).To(Equal(locator.ReasonExact))
+		g.Expect(result.URL).To(Equal("git+file://" + repo.dir + "//charts/mychart?ref=" + repo.v1))
+		g.Expect(filepath.Join(result.Path, "Chart.yaml")).To(BeARegularFile())
+		g.Expect(filepath.Join(result.Path, "..", "..", ".git")).ToNot(BeAnExistingFile())
+	})
+
+	t.Run("should use the remote HEAD without a ref", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart",
+			Version:         "~1.1",
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Commit).To(Equal(repo.v2))
+		g.Expect(result.Version).To(Equal("1.1.0"))
+		g.Expect(result.Reason).To(Equal(locator.ReasonConstraint))
+	})
+
+	t.Run("should resolve branches and commits", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		cacheDir := t.TempDir()
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart?ref=release",
+			RepositoryCache: cacheDir,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Commit).To(Equal(repo.v1))
+
+		result, err = locator.Locate(t.Context(), &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart?ref=" + repo.v2,
+			RepositoryCache: cacheDir,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Commit).To(Equal(repo.v2))
+	})
+
+	t.Run("should reuse cached checkouts offline", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		cacheDir := t.TempDir()
+		req := &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart?ref=v1.0.0",
+			RepositoryCache: cacheDir,
+		}
+
+		online, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		req.Offline = true
+
+		offline, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(offline).To(Equal(online))
+
+		_, err = locator.Locate(t.Context(), &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart?ref=main",
+			RepositoryCache: cacheDir,
+			Offline:         true,
+		})
+		g.Expect(locator.IsNotCachedError(err)).To(BeTrue())
+	})
+
+	t.Run("should keep every ref recorded concurrently", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		cacheDir := t.TempDir()
+		refs := map[string]string{"v1.0.0": repo.v1, "release": repo.v1, "main": repo.v2}
+
+		var wg sync.WaitGroup
+		for ref := range refs {
+			wg.Go(func() {
+				_, err := locator.Locate(t.Context(), &locator.Request{
+					Name:            "git+file://" + repo.dir + "//charts/mychart?ref=" + ref,
+					RepositoryCache: cacheDir,
+				})
+				g.Expect(err).ToNot(HaveOccurred())
+			})
+		}
+
+		wg.Wait()
+
+		for ref, commit := range refs {
+			result, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "git+file://" + repo.dir + "//charts/mychart?ref=" + ref,
+				RepositoryCache: cacheDir,
+				Offline:         true,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Commit).To(Equal(commit))
+		}
+	})
+
+	t.Run("should report unknown refs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart?ref=v9.9.9",
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(errors.Is(err, locator.ErrGitRefNotFound)).To(BeTrue())
+	})
+
+	t.Run("should check the chart version", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart?ref=v1.0.0",
+			Version:         "2.0.0",
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(errors.Is(err, locator.ErrVersionNotFound)).To(BeTrue())
+	})
+
+	t.Run("should refuse verification", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "git+file://" + repo.dir + "//charts/mychart?ref=v1.0.0",
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         "/keys/pubring.gpg",
+		})
+		g.Expect(errors.Is(err, locator.ErrProvenanceNotFound)).To(BeTrue())
+	})
+
+	t.Run("should authenticate to HTTPS remotes", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		backend := &cgi.Handler{
+			Path: filepath.Join(gitExecPath(t), "git-http-backend"),
+			Env:  []string{"GIT_PROJECT_ROOT=" + filepath.Dir(repo.dir), "GIT_HTTP_EXPORT_ALL=1"},
+		}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			backend.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+
+		ref := "git+" + srv.URL + "/" + filepath.Base(repo.dir) + "//charts/mychart?ref=v1.0.0"
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            ref,
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(err).To(HaveOccurred())
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            ref,
+			RepositoryCache: t.TempDir(),
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{Username: "user", Password: "pass"}, nil
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Commit).To(Equal(repo.v1))
+	})
+
+	t.Run("should reject malformed references", func(t *testing.T) {
+		t.Parallel()
+
+		for _, ref := range []string{
+			"git+ftp://example.com/repo//chart",
+			"git+https://example.com//chart",
+		} {
+			g := NewWithT(t)
+
+			_, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            ref,
+				RepositoryCache: t.TempDir(),
+			})
+			g.Expect(errors.Is(err, locator.ErrInvalidGitRef)).To(BeTrue(), ref)
+		}
+	})
+}
+
+// gitRepo is a test repository with charts/mychart at version 1.0.0 in
+// commit v1, tagged v1.0.0 and branch "release", and 1.1.0 in commit v2 on
+// the default branch "main".
+type gitRepo struct {
+	dir string
+	v1  string
+	v2  string
+}
+
+func newGitRepo(t *testing.T) *gitRepo {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := &gitRepo{dir: filepath.Join(t.TempDir(), "charts.git")}
+
+	gitCmd(t, "", "init", "--quiet", "--initial-branch=main", repo.dir)
+
+	writeChart := func(version string) {
+		dir := filepath.Join(repo.dir, "charts", "mychart")
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+
+		chartYAML := "apiVersion: v2\nname: mychart\nversion: " + version + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(chartYAML), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		gitCmd(t, repo.dir, "add", ".")
+		gitCmd(t, repo.dir, "commit", "--quiet", "--message", version)
+	}
+
+	writeChart("1.0.0")
+	repo.v1 = gitCmd(t, repo.dir, "rev-parse", "HEAD")
+	gitCmd(t, repo.dir, "tag", "--annotate", "--message", "v1.0.0", "v1.0.0")
+	gitCmd(t, repo.dir, "branch", "release")
+
+	writeChart("1.1.0")
+	repo.v2 = gitCmd(t, repo.dir, "rev-parse", "HEAD")
+
+	return repo
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.CommandContext(t.Context(), "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+func gitExecPath(t *testing.T) string {
+	t.Helper()
+
+	return gitCmd(t, "", "--exec-path")
+}
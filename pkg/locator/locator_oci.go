@@ -4,6 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+
+	godigest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"github.com/k8s-manifest-kit/renderer-helm/pkg/container"
 )
@@ -11,12 +15,31 @@ import (
 // ErrEmptyChartData is returned when an OCI pull returns no chart content.
 var ErrEmptyChartData = errors.New("OCI pull returned empty chart data")
 
+// DigestMismatchError is returned when a downloaded archive does not match the
+// digest from the OCI manifest or the repository index.
+type DigestMismatchError = container.DigestMismatchError
+
+// IsDigestMismatchError reports whether err or any error in its chain is a *DigestMismatchError.
+func IsDigestMismatchError(err error) bool {
+	return container.IsDigestMismatchError(err)
+}
+
+// CertificateError is returned when a repository or registry certificate is
+// rejected during the TLS handshake.
+type CertificateError = container.CertificateError
+
+// IsCertificateError reports whether err or any error in its chain is a *CertificateError.
+func IsCertificateError(err error) bool {
+	return container.IsCertificateError(err)
+}
+
 // Re-export container sentinel errors so existing locator consumers don't break.
 var (
 	ErrNoTags            = container.ErrNoTags
 	ErrRefContainsTag    = container.ErrRefContainsTag
 	ErrRefContainsDigest = container.ErrRefContainsDigest
 	ErrNoValidSemverTag  = container.ErrNoValidSemverTag
+	ErrNoMatchingTag     = container.ErrNoMatchingTag
 )
 
 // OCI resolves charts stored in an OCI-compatible registry.
@@ -26,6 +49,39 @@ type OCI struct {
 	Credentials *Credentials
 	CacheDir    string
 	PlainHTTP   bool
+
+	// Offline resolves the chart from CacheDir only, without contacting the
+	// registry. A chart that was never pulled yields a *NotCachedError.
+	Offline bool
+
+	// Keyring, when set, requires the chart to carry a provenance layer
+	// signed by a key in this OpenPGP keyring.
+	Keyring string
+
+	// Digest, when set, is the expected digest of the chart layer. Charts
+	// whose manifest refers to another layer fail with a *DigestMismatchError
+	// before the layer is downloaded.
+	Digest string
+
+	// Policy decides which tags are eligible for Version.
+	Policy ResolutionPolicy
+
+	// TLS configures TLS for registry connections when neither HTTPClient
+	// nor Transport is set.
+	TLS *TLSConfig
+
+	// HTTPClient, when set, is used for every registry request. Transport is
+	// used instead to build the client when HTTPClient is nil. Registry
+	// authentication is layered on top of either.
+	HTTPClient *http.Client
+	Transport  http.RoundTripper
+
+	// Retry is the policy the caller retries Locate with, as Locate does with
+	// Request.Retry. When it makes retries, the default registry client does
+	// not retry requests itself, so that transient failures are not retried
+	// twice, and the Retry-After delay of 429 and 503 responses is reported
+	// to it.
+	Retry *RetryPolicy
 }
 
 // Locate pulls the chart from an OCI registry and returns the local cache path.
@@ -34,64 +90,270 @@ func (o *OCI) Locate(ctx context.Context) (Result, error) {
 		return Result{}, ErrEmptyCacheDir
 	}
 
-	data, err := o.pull(ctx)
+	key, version, err := o.catalogRef()
 	if err != nil {
 		return Result{}, err
 	}
 
-	path, err := cacheChart(o.CacheDir, data)
+	if o.Offline {
+		return o.locateCached(key, version)
+	}
+
+	client, retryAfter, err := o.newClient()
+	if err != nil {
+		return Result{}, err
+	}
+
+	result, err := o.pull(ctx, client, key)
+	if err != nil {
+		return Result{}, retryAfter.wrap(err)
+	}
+
+	return result, nil
+}
+
+// pull resolves and downloads the chart of the catalog key from the registry.
+func (o *OCI) pull(ctx context.Context, client *container.Client, key string) (Result, error) {
+	layers, tag, err := o.resolveLayers(ctx, client)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if o.Digest != "" && layers.Chart.Digest != godigest.Digest(o.Digest) {
+		return Result{}, fmt.Errorf(
+			"unable to pull chart %q: %w",
+			o.Ref,
+			&DigestMismatchError{Expected: godigest.Digest(o.Digest), Actual: layers.Chart.Digest},
+		)
+	}
+
+	path, digest, err := o.fetchLayer(ctx, client, layers.Chart)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Path:       path,
+		SourceType: SourceOCI,
+		Version:    tag,
+		Reason:     o.reason(tag),
+		Digest:     digest,
+		URL:        key,
+	}
+
+	if tag != "" {
+		result.URL = client.Reference(tag)
+	}
+
+	if o.Keyring != "" {
+		result.Provenance, err = verifyChart(o.Keyring, path, ociArchiveFileName(key, tag, path), func() ([]byte, error) {
+			if layers.Provenance == nil {
+				return nil, fmt.Errorf("%w: %q has no provenance layer", ErrProvenanceNotFound, o.Ref)
+			}
+
+			return client.FetchLayer(ctx, *layers.Provenance)
+		})
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	if err := recordChart(o.CacheDir, key, catalogEntry{Version: tag, Digest: digest, URL: result.URL}); err != nil {
+		return Result{}, err
+	}
+
+	result.readMetadata()
+
+	return result, nil
+}
+
+// catalogRef returns the catalog key and version constraint for the ref.
+// Digest-pinned refs are keyed by digest and carry no version.
+func (o *OCI) catalogRef() (string, string, error) {
+	repoRef, err := container.RepositoryRef(o.Ref)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := "oci://" + repoRef
+
+	if dgst := container.EmbeddedDigest(o.Ref); dgst != "" {
+		if o.Version != "" {
+			return "", "", fmt.Errorf("%w: ref %q has digest %q, version %q", ErrRefContainsDigest, o.Ref, dgst, o.Version)
+		}
+
+		return key + "@" + dgst.String(), "", nil
+	}
+
+	tag := container.EmbeddedTag(o.Ref)
+	if tag != "" && o.Version != "" {
+		return "", "", fmt.Errorf("%w: ref %q has tag %q, version %q", ErrRefContainsTag, o.Ref, tag, o.Version)
+	}
+
+	if tag != "" {
+		return key, tag, nil
+	}
+
+	return key, o.Version, nil
+}
+
+// locateCached resolves the chart from the cache catalog. Digest-pinned refs
+// have a single catalog entry without a version, which the policy must not
+// filter out.
+func (o *OCI) locateCached(key string, version string) (Result, error) {
+	if container.EmbeddedDigest(o.Ref) != "" {
+		result, err := locateCached(o.CacheDir, key, "", SourceOCI, o.Keyring, versionPolicy{})
+		if err != nil {
+			return Result{}, err
+		}
+
+		result.Reason = ReasonDigest
+
+		return result, nil
+	}
+
+	policy, err := o.Policy.compile()
 	if err != nil {
 		return Result{}, err
 	}
 
-	return Result{Path: path, SourceType: SourceOCI}, nil
+	return locateCached(o.CacheDir, key, version, SourceOCI, o.Keyring, policy)
+}
+
+// reason reports why tag was selected; an empty tag means the ref is
+// pinned by digest.
+func (o *OCI) reason(tag string) ResolutionReason {
+	embedded := container.EmbeddedTag(o.Ref)
+
+	switch {
+	case tag == "":
+		return ReasonDigest
+	case embedded != "" || tag == o.Version:
+		return ReasonExact
+	case o.Version == "":
+		return ReasonLatest
+	default:
+		return ReasonConstraint
+	}
 }
 
-func (o *OCI) pull(ctx context.Context) ([]byte, error) {
-	var opts []container.ClientOption
+func (o *OCI) newClient() (*container.Client, *retryAfterTransport, error) {
+	opts := o.Policy.clientOptions()
 	if o.Credentials.hasAuth() {
-		opts = append(opts, container.WithCredential(o.Credentials.Username, o.Credentials.Password))
+		opts = append(opts, o.Credentials.clientOptions()...)
 	}
 
 	if o.PlainHTTP {
 		opts = append(opts, container.WithPlainHTTP(true))
 	}
 
+	var retryAfter *retryAfterTransport
+
+	switch {
+	case o.HTTPClient != nil && o.Retry.retries():
+		httpClient := *o.HTTPClient
+		retryAfter = &retryAfterTransport{base: httpClient.Transport}
+		httpClient.Transport = retryAfter
+
+		opts = append(opts, container.WithHTTPClient(&httpClient))
+	case o.HTTPClient != nil:
+		opts = append(opts, container.WithHTTPClient(o.HTTPClient))
+	case o.Transport != nil || o.Retry.retries():
+		transport, err := o.transport()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if o.Retry.retries() {
+			retryAfter = &retryAfterTransport{base: transport}
+			transport = retryAfter
+		}
+
+		opts = append(opts, container.WithTransport(transport))
+	case o.TLS != nil:
+		tlsConfig, err := o.TLS.build()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to configure TLS for %q: %w", o.Ref, err)
+		}
+
+		opts = append(opts, container.WithTLSConfig(tlsConfig))
+	}
+
 	client, err := container.NewClient(o.Ref, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create OCI client: %w", err)
+		return nil, nil, fmt.Errorf("unable to create OCI client: %w", err)
 	}
 
-	if dgst := container.EmbeddedDigest(o.Ref); dgst != "" {
-		if o.Version != "" {
-			return nil, fmt.Errorf("%w: ref %q has digest %q, version %q", ErrRefContainsDigest, o.Ref, dgst, o.Version)
-		}
+	return client, retryAfter, nil
+}
+
+// transport returns Transport, or without one a transport without the
+// retries of the default registry client, configured with TLS.
+func (o *OCI) transport() (http.RoundTripper, error) {
+	if o.Transport != nil {
+		return o.Transport, nil
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		transport = &http.Transport{}
+	}
+
+	transport = transport.Clone()
 
-		data, err := client.PullDigest(ctx, dgst)
+	if o.TLS != nil {
+		tlsConfig, err := o.TLS.build()
 		if err != nil {
-			return nil, fmt.Errorf("unable to pull chart by digest: %w", err)
+			return nil, fmt.Errorf("unable to configure TLS for %q: %w", o.Ref, err)
 		}
 
-		if len(data) == 0 {
-			return nil, fmt.Errorf("%w: %q", ErrEmptyChartData, o.Ref)
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// resolveLayers returns the chart layer descriptors and the tag they were
+// resolved from, which is empty for digest-pinned refs. Only manifests are
+// fetched.
+func (o *OCI) resolveLayers(ctx context.Context, client *container.Client) (container.ChartLayers, string, error) {
+	if dgst := container.EmbeddedDigest(o.Ref); dgst != "" {
+		layers, err := client.LayersDigest(ctx, dgst)
+		if err != nil {
+			return container.ChartLayers{}, "", fmt.Errorf("unable to pull chart by digest: %w", err)
 		}
 
-		return data, nil
+		return layers, "", nil
 	}
 
 	tag, err := client.ResolveTag(ctx, o.Version)
 	if err != nil {
-		return nil, fmt.Errorf("unable to resolve tag: %w", err)
+		return container.ChartLayers{}, "", fmt.Errorf("unable to resolve tag: %w", err)
+	}
+
+	layers, err := client.Layers(ctx, tag)
+	if err != nil {
+		return container.ChartLayers{}, "", fmt.Errorf("unable to pull chart by tag: %w", err)
+	}
+
+	return layers, tag, nil
+}
+
+// fetchLayer returns the cached archive for layer when one exists and
+// downloads it otherwise.
+func (o *OCI) fetchLayer(ctx context.Context, client *container.Client, layer ocispec.Descriptor) (string, string, error) {
+	if path, ok := lookupCachedChart(o.CacheDir, layer.Digest.String()); ok {
+		return path, layer.Digest.String(), nil
 	}
 
-	data, err := client.Pull(ctx, tag)
+	data, err := client.FetchLayer(ctx, layer)
 	if err != nil {
-		return nil, fmt.Errorf("unable to pull chart by tag: %w", err)
+		return "", "", fmt.Errorf("unable to pull chart: %w", err)
 	}
 
 	if len(data) == 0 {
-		return nil, fmt.Errorf("%w: %q", ErrEmptyChartData, o.Ref)
+		return "", "", fmt.Errorf("%w: %q", ErrEmptyChartData, o.Ref)
 	}
 
-	return data, nil
+	return cacheChart(o.CacheDir, data)
 }
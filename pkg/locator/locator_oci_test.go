@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"testing"
 
 	"github.com/opencontainers/go-digest"
@@ -120,6 +122,70 @@ func TestOCILocator_DigestPin(t *testing.T) {
 	})
 }
 
+func TestOCILocator_CachedLayer(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	chartContent := []byte("cached-layer-chart")
+	srv := newMockOCIRegistry(t, chartContent)
+	cacheDir := t.TempDir()
+
+	oci := &locator.OCI{
+		Ref:       "oci://" + srv.ref,
+		Version:   "1.0.0",
+		CacheDir:  cacheDir,
+		PlainHTTP: true,
+	}
+
+	first, err := oci.Locate(t.Context())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(filepath.Base(first.Path)).To(Equal(digest.FromBytes(chartContent).Encoded() + ".tgz"))
+
+	second, err := oci.Locate(t.Context())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(second.Path).To(Equal(first.Path))
+	g.Expect(srv.blobFetches.Load()).To(Equal(int32(1)))
+}
+
+func TestOCILocator_ExpectedDigest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should pull charts matching the expected digest", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		chartContent := []byte("expected-digest-chart")
+		srv := newMockOCIRegistry(t, chartContent)
+
+		result, err := (&locator.OCI{
+			Ref:       "oci://" + srv.ref,
+			Version:   "1.0.0",
+			Digest:    digest.FromBytes(chartContent).String(),
+			CacheDir:  t.TempDir(),
+			PlainHTTP: true,
+		}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Digest).To(Equal(digest.FromBytes(chartContent).String()))
+	})
+
+	t.Run("should refuse other layers before downloading them", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newMockOCIRegistry(t, []byte("repushed-chart"))
+
+		_, err := (&locator.OCI{
+			Ref:       "oci://" + srv.ref,
+			Version:   "1.0.0",
+			Digest:    digest.FromBytes([]byte("locked-chart")).String(),
+			CacheDir:  t.TempDir(),
+			PlainHTTP: true,
+		}).Locate(t.Context())
+		g.Expect(locator.IsDigestMismatchError(err)).To(BeTrue())
+		g.Expect(srv.blobFetches.Load()).To(BeZero())
+	})
+}
+
 func TestOCILocator_Integration(t *testing.T) {
 	t.Parallel()
 
@@ -169,6 +235,40 @@ func TestOCILocator_Integration(t *testing.T) {
 	})
 }
 
+func TestOCILocator_TokenCredentials(t *testing.T) {
+	t.Parallel()
+	g := NewWithT(t)
+
+	registry := newMockOCIRegistry(t, []byte("token-oci-chart"))
+
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("X-Api-Key") != "key" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		registry.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	result, err := (&locator.OCI{
+		Ref:       "oci://" + srv.Listener.Addr().String() + "/test/chart",
+		Version:   "1.0.0",
+		CacheDir:  t.TempDir(),
+		PlainHTTP: true,
+		Credentials: &locator.Credentials{
+			BearerToken: "token",
+			Headers:     map[string]string{"X-Api-Key": "key"},
+		},
+	}).Locate(t.Context())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Path).To(BeARegularFile())
+}
+
 func TestOCILocator_DigestPullEmptyBlob(t *testing.T) {
 	t.Parallel()
 	g := NewWithT(t)
@@ -197,6 +297,7 @@ type mockOCIServer struct {
 
 	ref            string
 	manifestDigest digest.Digest
+	blobFetches    *atomic.Int32
 }
 
 // newMockOCIRegistry spins up a lightweight mock OCI registry for locator-level
@@ -205,6 +306,14 @@ type mockOCIServer struct {
 func newMockOCIRegistry(t *testing.T, chartContent []byte) *mockOCIServer {
 	t.Helper()
 
+	return newMockOCIRegistryWithProvenance(t, chartContent, nil)
+}
+
+// newMockOCIRegistryWithProvenance is newMockOCIRegistry with an additional
+// provenance layer when provContent is non-nil.
+func newMockOCIRegistryWithProvenance(t *testing.T, chartContent []byte, provContent []byte) *mockOCIServer {
+	t.Helper()
+
 	chartDigest := digest.FromBytes(chartContent)
 	configContent := []byte(`{"name":"test-chart","version":"1.0.0"}`)
 	configDigest := digest.FromBytes(configContent)
@@ -223,6 +332,15 @@ func newMockOCIRegistry(t *testing.T, chartContent []byte) *mockOCIServer {
 		}},
 	}
 
+	provDigest := digest.FromBytes(provContent)
+	if provContent != nil {
+		manifest.Layers = append(manifest.Layers, ocispec.Descriptor{
+			MediaType: "application/vnd.cncf.helm.chart.provenance.v1.prov",
+			Digest:    provDigest,
+			Size:      int64(len(provContent)),
+		})
+	}
+
 	manifestBytes, err := json.Marshal(manifest)
 	if err != nil {
 		t.Fatal(err)
@@ -235,8 +353,14 @@ func newMockOCIRegistry(t *testing.T, chartContent []byte) *mockOCIServer {
 		configDigest: configContent,
 	}
 
+	if provContent != nil {
+		blobs[provDigest] = provContent
+	}
+
 	const repo = "test/chart"
 
+	var blobFetches atomic.Int32
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc(fmt.Sprintf("/v2/%s/manifests/", repo), func(w http.ResponseWriter, _ *http.Request) {
@@ -263,6 +387,10 @@ func newMockOCIRegistry(t *testing.T, chartContent []byte) *mockOCIServer {
 			return
 		}
 
+		if d == chartDigest {
+			blobFetches.Add(1)
+		}
+
 		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 		w.Header().Set("Docker-Content-Digest", d.String())
 		_, _ = w.Write(data)
@@ -277,5 +405,6 @@ func newMockOCIRegistry(t *testing.T, chartContent []byte) *mockOCIServer {
 		Server:         srv,
 		ref:            host + "/" + repo,
 		manifestDigest: manifestDigest,
+		blobFetches:    &blobFetches,
 	}
 }
@@ -0,0 +1,124 @@
+package locator
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/container"
+)
+
+// ErrVersionBelowMinimum is returned when an explicitly requested version is
+// lower than ResolutionPolicy.MinVersion.
+var ErrVersionBelowMinimum = container.ErrVersionBelowMinimum
+
+// ResolutionReason describes why a chart version was selected.
+type ResolutionReason string
+
+const (
+	// ReasonExact indicates the requested version or embedded tag was used as is.
+	ReasonExact ResolutionReason = "exact"
+	// ReasonConstraint indicates the highest eligible version satisfying the
+	// requested constraint was selected.
+	ReasonConstraint ResolutionReason = "constraint"
+	// ReasonLatest indicates no version was requested and the highest eligible
+	// version was selected.
+	ReasonLatest ResolutionReason = "latest"
+	// ReasonDigest indicates the chart was pinned by an OCI manifest digest.
+	ReasonDigest ResolutionReason = "digest"
+)
+
+// ResolutionPolicy controls which chart versions are eligible when the latest
+// version or the highest match for a constraint is selected. The zero value
+// excludes prereleases, matching Helm's default behavior.
+type ResolutionPolicy struct {
+	// Prereleases includes versions such as "1.0.0-rc.1". A prerelease that
+	// is requested exactly, or by a constraint with a prerelease part, is
+	// always eligible.
+	Prereleases bool
+
+	// SkipDeprecated ignores repository index entries marked deprecated
+	// unless they are requested exactly. OCI registries carry no deprecation
+	// flag per tag, so it has no effect on OCI charts.
+	SkipDeprecated bool
+
+	// MinVersion rejects semver versions lower than this version, including
+	// exactly requested ones. While it is set, versions that are not semver
+	// are only used when requested exactly.
+	MinVersion string
+}
+
+// versionPolicy is a ResolutionPolicy with MinVersion parsed.
+type versionPolicy struct {
+	prereleases    bool
+	skipDeprecated bool
+	minVersion     *semver.Version
+}
+
+func (p ResolutionPolicy) compile() (versionPolicy, error) {
+	vp := versionPolicy{prereleases: p.Prereleases, skipDeprecated: p.SkipDeprecated}
+
+	if p.MinVersion != "" {
+		v, err := semver.NewVersion(p.MinVersion)
+		if err != nil {
+			return versionPolicy{}, fmt.Errorf("invalid minimum version %q: %w", p.MinVersion, err)
+		}
+
+		vp.minVersion = v
+	}
+
+	return vp, nil
+}
+
+// clientOptions returns the OCI client options enforcing the policy.
+func (p ResolutionPolicy) clientOptions() []container.ClientOption {
+	var opts []container.ClientOption
+
+	if p.Prereleases {
+		opts = append(opts, container.WithPrereleases(true))
+	}
+
+	if p.MinVersion != "" {
+		opts = append(opts, container.WithMinVersion(p.MinVersion))
+	}
+
+	return opts
+}
+
+// eligible reports whether cv, parsed as v, may be selected as the latest
+// version. A nil v means the version is not semver.
+func (p versionPolicy) eligible(cv *repoChartVersion, v *semver.Version) bool {
+	if v != nil && v.Prerelease() != "" && !p.prereleases {
+		return false
+	}
+
+	return p.matchable(cv, v)
+}
+
+// matchable reports whether cv, parsed as v, may be selected at all once the
+// version constraint has been checked. Prereleases are left to the constraint.
+func (p versionPolicy) matchable(cv *repoChartVersion, v *semver.Version) bool {
+	if p.skipDeprecated && cv.Deprecated {
+		return false
+	}
+
+	if v == nil {
+		return p.minVersion == nil
+	}
+
+	return p.aboveFloor(v)
+}
+
+func (p versionPolicy) aboveFloor(v *semver.Version) bool {
+	return p.minVersion == nil || !v.LessThan(p.minVersion)
+}
+
+// checkExact rejects an exactly requested version below the floor.
+func (p versionPolicy) checkExact(version string) error {
+	v, err := semver.NewVersion(version)
+	if err != nil || p.aboveFloor(v) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q is lower than %q", ErrVersionBelowMinimum, version, p.minVersion.Original())
+}
@@ -0,0 +1,233 @@
+package locator_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+const policyIndexYAML = `apiVersion: v1
+entries:
+  mychart:
+    - version: "3.0.0-rc.1"
+      urls:
+        - mychart-3.0.0-rc.1.tgz
+    - version: "2.1.0"
+      deprecated: true
+      urls:
+        - mychart-2.1.0.tgz
+    - version: "2.0.0"
+      urls:
+        - mychart-2.0.0.tgz
+    - version: "1.5.0"
+      urls:
+        - mychart-1.5.0.tgz
+`
+
+func TestLocate_ResolutionPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		version string
+		policy  locator.ResolutionPolicy
+		want    string
+		reason  locator.ResolutionReason
+	}{
+		{
+			name:   "should exclude prereleases from latest by default",
+			want:   "2.1.0",
+			reason: locator.ReasonLatest,
+		},
+		{
+			name:   "should include prereleases in latest when allowed",
+			policy: locator.ResolutionPolicy{Prereleases: true},
+			want:   "3.0.0-rc.1",
+			reason: locator.ReasonLatest,
+		},
+		{
+			name:    "should include prereleases in constraints when allowed",
+			version: ">=2",
+			policy:  locator.ResolutionPolicy{Prereleases: true},
+			want:    "3.0.0-rc.1",
+			reason:  locator.ReasonConstraint,
+		},
+		{
+			name:   "should skip deprecated versions for latest",
+			policy: locator.ResolutionPolicy{SkipDeprecated: true},
+			want:   "2.0.0",
+			reason: locator.ReasonLatest,
+		},
+		{
+			name:    "should skip deprecated versions for constraints",
+			version: "^2",
+			policy:  locator.ResolutionPolicy{SkipDeprecated: true},
+			want:    "2.0.0",
+			reason:  locator.ReasonConstraint,
+		},
+		{
+			name:    "should honor an exact deprecated version",
+			version: "2.1.0",
+			policy:  locator.ResolutionPolicy{SkipDeprecated: true},
+			want:    "2.1.0",
+			reason:  locator.ReasonExact,
+		},
+		{
+			name:    "should honor an exact prerelease version",
+			version: "3.0.0-rc.1",
+			want:    "3.0.0-rc.1",
+			reason:  locator.ReasonExact,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g := NewWithT(t)
+
+			srv := newPolicyChartServer(t)
+
+			result, err := locator.Locate(t.Context(), &locator.Request{
+				Name:             "mychart",
+				RepoURL:          srv.URL,
+				Version:          tt.version,
+				RepositoryCache:  t.TempDir(),
+				ResolutionPolicy: tt.policy,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result).To(MatchFields(IgnoreExtras, Fields{
+				"Version": Equal(tt.want),
+				"Reason":  Equal(tt.reason),
+			}))
+		})
+	}
+
+	t.Run("should skip versions below the minimum", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newPolicyChartServer(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "mychart",
+			RepoURL:          srv.URL,
+			Version:          "<2.0.0",
+			RepositoryCache:  t.TempDir(),
+			ResolutionPolicy: locator.ResolutionPolicy{MinVersion: "1.6.0"},
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrVersionNotFound)).To(BeTrue())
+	})
+
+	t.Run("should reject an exact version below the minimum", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newPolicyChartServer(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "mychart",
+			RepoURL:          srv.URL,
+			Version:          "1.5.0",
+			RepositoryCache:  t.TempDir(),
+			ResolutionPolicy: locator.ResolutionPolicy{MinVersion: "2.0.0"},
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrVersionBelowMinimum)).To(BeTrue())
+	})
+
+	t.Run("should reject an invalid minimum version", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "mychart",
+			RepoURL:          "https://charts.example.com",
+			RepositoryCache:  t.TempDir(),
+			ResolutionPolicy: locator.ResolutionPolicy{MinVersion: "not-a-version"},
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("invalid minimum version"))
+	})
+
+	t.Run("should apply the policy offline", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newPolicyChartServer(t)
+		cacheDir := t.TempDir()
+
+		for _, version := range []string{"2.1.0", "2.0.0"} {
+			_, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         version,
+				RepositoryCache: cacheDir,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "mychart",
+			RepoURL:          srv.URL,
+			RepositoryCache:  cacheDir,
+			Offline:          true,
+			ResolutionPolicy: locator.ResolutionPolicy{SkipDeprecated: true},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Version).To(Equal("2.0.0"))
+		g.Expect(result.Reason).To(Equal(locator.ReasonLatest))
+	})
+
+	t.Run("should report exact and digest reasons for OCI charts", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newMockOCIRegistry(t, []byte("policy-oci-chart"))
+
+		result, err := (&locator.OCI{
+			Ref:       "oci://" + srv.ref,
+			Version:   "1.0.0",
+			CacheDir:  t.TempDir(),
+			PlainHTTP: true,
+		}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Version).To(Equal("1.0.0"))
+		g.Expect(result.Reason).To(Equal(locator.ReasonExact))
+
+		result, err = (&locator.OCI{
+			Ref:       fmt.Sprintf("oci://%s@%s", srv.ref, srv.manifestDigest),
+			CacheDir:  t.TempDir(),
+			PlainHTTP: true,
+		}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Version).To(BeEmpty())
+		g.Expect(result.Reason).To(Equal(locator.ReasonDigest))
+	})
+}
+
+func newPolicyChartServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == indexPath {
+			_, _ = w.Write([]byte(policyIndexYAML))
+
+			return
+		}
+
+		// Serve distinct content per version so that each gets its own
+		// cache entry.
+		_, _ = w.Write([]byte(chartData + r.URL.Path))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
@@ -0,0 +1,226 @@
+package locator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"helm.sh/helm/v4/pkg/provenance"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/internal/fsutil"
+)
+
+const provenanceSuffix = ".prov"
+
+var (
+	// ErrEmptyKeyring is returned when verification is requested without a keyring.
+	ErrEmptyKeyring = errors.New("keyring must not be empty when verification is enabled")
+
+	// ErrProvenanceNotFound is returned when a chart has no provenance file to verify.
+	ErrProvenanceNotFound = errors.New("provenance file not found")
+
+	// ErrInvalidProvenance is returned when a provenance file cannot be parsed.
+	ErrInvalidProvenance = errors.New("invalid provenance file")
+
+	// ErrInvalidSignature is returned when the provenance signature cannot be
+	// verified with any key in the keyring.
+	ErrInvalidSignature = errors.New("provenance signature verification failed")
+
+	// ErrArchiveNotSigned is returned when the signed provenance file has no
+	// hash for the archive's file name, or a different one.
+	ErrArchiveNotSigned = errors.New("chart archive hash does not match provenance")
+)
+
+// Verification describes a chart whose provenance file was verified.
+type Verification struct {
+	// SignedBy is the primary identity of the signing key,
+	// e.g. "Jane Doe <jane@example.com>".
+	SignedBy string
+
+	// Fingerprint is the hex fingerprint of the signing key.
+	Fingerprint string
+
+	// FileName is the archive name recorded in the provenance file.
+	FileName string
+
+	// FileHash is the signed archive digest in "sha256:<hex>" form.
+	FileHash string
+}
+
+// VerifyChart verifies the chart archive at archivePath against prov, a
+// provenance file that must sign the archive's hash under fileName, with the
+// OpenPGP keyring at keyringPath. It lets registered locators fill
+// Result.Provenance when SchemeRequest.Keyring is set. A nil prov reads the
+// provenance file next to the archive; otherwise prov is stored there once
+// verified, so that the chart can be verified again offline.
+func VerifyChart(keyringPath string, archivePath string, fileName string, prov []byte) (*Verification, error) {
+	if prov == nil {
+		return verifyChart(keyringPath, archivePath, fileName, nil)
+	}
+
+	verification, err := checkProvenance(keyringPath, archivePath, fileName, prov)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fsutil.WriteFileAtomic(archivePath+provenanceSuffix, prov); err != nil {
+		return nil, fmt.Errorf("unable to cache provenance file: %w", err)
+	}
+
+	return verification, nil
+}
+
+// verifyChart verifies the archive at archivePath against its provenance file,
+// which must sign the archive's hash under fileName. Cached archives are named
+// by digest, so fileName is the name the chart was published under.
+// A provenance file next to the archive is used when present; otherwise it is
+// obtained from fetchProv and stored there after a successful verification.
+// A nil fetchProv means the provenance must already be on disk.
+func verifyChart(
+	keyringPath string,
+	archivePath string,
+	fileName string,
+	fetchProv func() ([]byte, error),
+) (*Verification, error) {
+	provPath := archivePath + provenanceSuffix
+
+	prov, err := os.ReadFile(provPath) //nolint:gosec // path is derived from the chart path
+	switch {
+	case err == nil:
+		fetchProv = nil
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("unable to read provenance file %q: %w", provPath, err)
+	case fetchProv == nil:
+		return nil, fmt.Errorf("%w: %s", ErrProvenanceNotFound, provPath)
+	default:
+		prov, err = fetchProv()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	verification, err := checkProvenance(keyringPath, archivePath, fileName, prov)
+	if err != nil {
+		return nil, err
+	}
+
+	if fetchProv != nil {
+		if err := fsutil.WriteFileAtomic(provPath, prov); err != nil {
+			return nil, fmt.Errorf("unable to cache provenance file: %w", err)
+		}
+	}
+
+	return verification, nil
+}
+
+// checkProvenance verifies the archive at archivePath against prov.
+func checkProvenance(keyringPath string, archivePath string, fileName string, prov []byte) (*Verification, error) {
+	archive, err := os.ReadFile(archivePath) //nolint:gosec // path is derived from the cache directory
+	if err != nil {
+		return nil, fmt.Errorf("unable to read chart archive %q: %w", archivePath, err)
+	}
+
+	signatory, err := loadKeyring(keyringPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyProvenance(signatory, archive, prov, fileName)
+}
+
+// loadKeyring reads an OpenPGP public keyring. Binary keyrings, as read by
+// Helm, are tried first; ASCII-armored keyrings are accepted as well.
+func loadKeyring(keyringPath string) (*provenance.Signatory, error) {
+	if keyringPath == "" {
+		return nil, ErrEmptyKeyring
+	}
+
+	signatory, err := provenance.NewFromKeyring(keyringPath, "")
+	if err == nil {
+		return signatory, nil
+	}
+
+	data, readErr := os.ReadFile(keyringPath) //nolint:gosec // caller controls path
+	if readErr != nil {
+		return nil, fmt.Errorf("unable to read keyring %q: %w", keyringPath, readErr)
+	}
+
+	keyring, armoredErr := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if armoredErr != nil {
+		return nil, fmt.Errorf("unable to parse keyring %q: %w", keyringPath, err)
+	}
+
+	return &provenance.Signatory{KeyRing: keyring}, nil
+}
+
+// verifyProvenance checks the clearsigned provenance with Helm's verifier,
+// which requires the signed files section to hold the archive's sha256 under
+// fileName. Helm's errors are mapped to the sentinel errors of this package.
+func verifyProvenance(
+	signatory *provenance.Signatory,
+	archive []byte,
+	prov []byte,
+	fileName string,
+) (*Verification, error) {
+	block, _ := clearsign.Decode(prov)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no clearsigned block", ErrInvalidProvenance)
+	}
+
+	var sums provenance.SumCollection
+	if err := provenance.ParseMessageBlock(block.Plaintext, nil, &sums); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProvenance, err)
+	}
+
+	verification, err := signatory.Verify(archive, prov, fileName)
+	switch {
+	case err == nil:
+	case verification.SignedBy == nil:
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	default:
+		return nil, fmt.Errorf("%w: %w", ErrArchiveNotSigned, err)
+	}
+
+	signedBy := ""
+	if id := verification.SignedBy.PrimaryIdentity(); id != nil {
+		signedBy = id.Name
+	}
+
+	return &Verification{
+		SignedBy:    signedBy,
+		Fingerprint: fmt.Sprintf("%X", verification.SignedBy.PrimaryKey.Fingerprint),
+		FileName:    verification.FileName,
+		FileHash:    verification.FileHash,
+	}, nil
+}
+
+// archiveFileName returns the file name of the chart archive at chartURL,
+// which is the name its provenance file signs.
+func archiveFileName(chartURL string) string {
+	if u, err := url.Parse(chartURL); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+
+	return path.Base(chartURL)
+}
+
+// ociArchiveFileName returns the archive name Helm signs for the chart pushed
+// to the repository of the catalog key: the last repository path element is
+// the chart name. Charts pulled by digest use the version of their Chart.yaml.
+func ociArchiveFileName(key string, version string, archivePath string) string {
+	if version == "" {
+		if meta, err := chartMetadata(archivePath); err == nil {
+			version = meta.Version
+		}
+	}
+
+	repository, _, _ := strings.Cut(strings.TrimPrefix(key, "oci://"), "@")
+
+	return path.Base(repository) + "-" + version + ".tgz"
+}
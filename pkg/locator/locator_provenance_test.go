@@ -0,0 +1,358 @@
+package locator_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"helm.sh/helm/v4/pkg/provenance"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+func TestLocate_Provenance(t *testing.T) {
+	t.Parallel()
+
+	signer := newTestSigner(t, "Chart Signer", "signer@example.com")
+	keyring := writeKeyring(t, signer)
+	prov := signProvenance(t, signer, "mychart-1.0.0.tgz", []byte(chartData))
+
+	t.Run("should verify repo chart and report the signer", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newProvenanceChartServer(t, prov)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         keyring,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Provenance).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"SignedBy":    Equal("Chart Signer <signer@example.com>"),
+			"Fingerprint": Equal(fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)),
+			"FileName":    Equal("mychart-1.0.0.tgz"),
+			"FileHash":    Equal(fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(chartData)))),
+		})))
+		g.Expect(result.Path + ".prov").To(BeARegularFile())
+	})
+
+	t.Run("should verify offline with the cached provenance file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newProvenanceChartServer(t, prov)
+		cacheDir := t.TempDir()
+		req := &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: cacheDir,
+			Verify:          true,
+			Keyring:         keyring,
+		}
+
+		_, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		srv.Close()
+		req.Offline = true
+
+		result, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Provenance).ToNot(BeNil())
+	})
+
+	t.Run("should fail when the repo has no provenance file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newProvenanceChartServer(t, nil)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         keyring,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrProvenanceNotFound)).To(BeTrue())
+	})
+
+	t.Run("should fail when the signer is not in the keyring", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		other := newTestSigner(t, "Someone Else", "else@example.com")
+		srv := newProvenanceChartServer(t, prov)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         writeKeyring(t, other),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrInvalidSignature)).To(BeTrue())
+	})
+
+	t.Run("should fail when the archive hash is not signed", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newProvenanceChartServer(t, signProvenance(t, signer, "mychart-1.0.0.tgz", []byte("other-archive")))
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         keyring,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrArchiveNotSigned)).To(BeTrue())
+	})
+
+	t.Run("should fail when the archive is signed under another file name", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newProvenanceChartServer(t, signProvenance(t, signer, "otherchart-1.0.0.tgz", []byte(chartData)))
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         keyring,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrArchiveNotSigned)).To(BeTrue())
+	})
+
+	t.Run("should accept ASCII-armored keyrings", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newProvenanceChartServer(t, prov)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+			Keyring:         writeArmoredKeyring(t, signer),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Provenance).ToNot(BeNil())
+	})
+
+	t.Run("should require a keyring", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "https://charts.example.com",
+			RepositoryCache: t.TempDir(),
+			Verify:          true,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrEmptyKeyring)).To(BeTrue())
+	})
+
+	t.Run("should verify OCI chart with a provenance layer", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		chartContent := []byte("signed-oci-chart")
+		srv := newMockOCIRegistryWithProvenance(t, chartContent,
+			signProvenance(t, signer, "chart-1.0.0.tgz", chartContent))
+
+		result, err := (&locator.OCI{
+			Ref:       "oci://" + srv.ref,
+			Version:   "1.0.0",
+			CacheDir:  t.TempDir(),
+			PlainHTTP: true,
+			Keyring:   keyring,
+		}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Provenance).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"SignedBy": Equal("Chart Signer <signer@example.com>"),
+			"FileName": Equal("chart-1.0.0.tgz"),
+		})))
+	})
+
+	t.Run("should fail for OCI chart without a provenance layer", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newMockOCIRegistry(t, []byte("unsigned-oci-chart"))
+
+		_, err := (&locator.OCI{
+			Ref:       "oci://" + srv.ref,
+			Version:   "1.0.0",
+			CacheDir:  t.TempDir(),
+			PlainHTTP: true,
+			Keyring:   keyring,
+		}).Locate(t.Context())
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrProvenanceNotFound)).To(BeTrue())
+	})
+
+	t.Run("should verify local archive with a sibling provenance file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		archive := filepath.Join(t.TempDir(), "mychart-1.0.0.tgz")
+		g.Expect(os.WriteFile(archive, []byte(chartData), 0600)).To(Succeed())
+		g.Expect(os.WriteFile(archive+".prov", prov, 0600)).To(Succeed())
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:    archive,
+			Verify:  true,
+			Keyring: keyring,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.SourceType).To(Equal(locator.SourceLocal))
+		g.Expect(result.Provenance).ToNot(BeNil())
+	})
+
+	t.Run("should fail for unpacked local chart directories", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:    t.TempDir(),
+			Verify:  true,
+			Keyring: keyring,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrProvenanceNotFound)).To(BeTrue())
+	})
+}
+
+// newTestSigner creates an Ed25519 signing key, which Helm's verifier
+// supports next to RSA.
+func newTestSigner(t *testing.T, name string, email string) *openpgp.Entity {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity(name, "", email, &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entity
+}
+
+// writeKeyring writes the public keys of the given entities as a binary keyring.
+func writeKeyring(t *testing.T, entities ...*openpgp.Entity) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for _, e := range entities {
+		if err := e.Serialize(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "pubring.gpg")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// writeArmoredKeyring writes the public key of entity as an ASCII-armored keyring.
+func writeArmoredKeyring(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pubring.asc")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// signProvenance signs archive under fileName the way "helm package --sign" does.
+func signProvenance(t *testing.T, signer *openpgp.Entity, fileName string, archive []byte) []byte {
+	t.Helper()
+
+	prov, err := (&provenance.Signatory{Entity: signer}).ClearSign(
+		archive,
+		fileName,
+		[]byte("apiVersion: v2\nname: mychart\nversion: 1.0.0\n"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte(prov)
+}
+
+// newProvenanceChartServer serves repoIndexYAML, chartData for archives and
+// prov for "*.prov" requests, or 404 when prov is nil.
+func newProvenanceChartServer(t *testing.T, prov []byte) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == indexPath:
+			_, _ = w.Write([]byte(repoIndexYAML))
+		case strings.HasSuffix(r.URL.Path, ".prov"):
+			if prov == nil {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			_, _ = w.Write(prov)
+		default:
+			_, _ = w.Write([]byte(chartData))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
@@ -0,0 +1,118 @@
+package locator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/container"
+)
+
+// Registry holds connection settings for the OCI registries whose host
+// matches Host.
+type Registry struct {
+	// Host is a registry host such as "registry.local:5000", or a path.Match
+	// pattern such as "*.internal". A pattern with a port is matched against
+	// the host and port, one without against the host name only.
+	Host string
+
+	// PlainHTTP talks to the registry over HTTP instead of HTTPS.
+	PlainHTTP bool
+
+	// Insecure accepts any certificate presented by the registry.
+	Insecure bool
+}
+
+// matches reports whether the registry settings apply to host, given as
+// host[:port].
+func (r *Registry) matches(host string) (bool, error) {
+	name := host
+	if !strings.Contains(r.Host, ":") {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			name = h
+		}
+	}
+
+	ok, err := path.Match(r.Host, name)
+	if err != nil {
+		return false, fmt.Errorf("invalid registry host pattern %q: %w", r.Host, err)
+	}
+
+	return ok, nil
+}
+
+func newOCI(
+	ctx context.Context,
+	req *Request,
+	name string,
+	version string,
+	creds *Credentials,
+	keyring string,
+) (*OCI, error) {
+	oci := &OCI{
+		Ref:         name,
+		Version:     version,
+		Credentials: creds,
+		CacheDir:    req.RepositoryCache,
+		PlainHTTP:   req.PlainHTTP,
+		Offline:     req.Offline,
+		Keyring:     keyring,
+		Digest:      req.Digest,
+		Policy:      req.ResolutionPolicy,
+		TLS:         req.TLS,
+		HTTPClient:  req.HTTPClient,
+		Transport:   req.Transport,
+		Retry:       req.Retry,
+	}
+
+	host := registryHost(name)
+
+	if !req.Offline && !creds.hasAuth() && host != "" {
+		c, err := providerCredentials(ctx, req.CredentialProviders, host)
+		if err != nil {
+			return nil, err
+		}
+
+		oci.Credentials = c
+	}
+
+	for i := range req.Registries {
+		ok, err := req.Registries[i].matches(host)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		oci.PlainHTTP = oci.PlainHTTP || req.Registries[i].PlainHTTP
+
+		if req.Registries[i].Insecure {
+			var cfg TLSConfig
+			if oci.TLS != nil {
+				cfg = *oci.TLS
+			}
+
+			cfg.InsecureSkipVerify = true
+			oci.TLS = &cfg
+		}
+	}
+
+	return oci, nil
+}
+
+// registryHost returns the host[:port] of an OCI ref, or "" for an invalid
+// ref, which Locate reports.
+func registryHost(ref string) string {
+	repoRef, err := container.RepositoryRef(ref)
+	if err != nil {
+		return ""
+	}
+
+	host, _, _ := strings.Cut(repoRef, "/")
+
+	return host
+}
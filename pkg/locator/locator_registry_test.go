@@ -0,0 +1,113 @@
+package locator_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLocate_Registries(t *testing.T) {
+	t.Parallel()
+
+	// locate pulls from a plain HTTP mock registry and returns the schemes of
+	// the requests it sent. Without plain HTTP configured the first request
+	// goes out over HTTPS before the loopback fallback kicks in.
+	locate := func(t *testing.T, plainHTTP bool, registries ...locator.Registry) []string {
+		t.Helper()
+		g := NewWithT(t)
+
+		srv := newMockOCIRegistry(t, []byte("registry-chart"))
+
+		var (
+			mu      sync.Mutex
+			schemes []string
+		)
+
+		transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			mu.Lock()
+			schemes = append(schemes, r.URL.Scheme)
+			mu.Unlock()
+
+			return http.DefaultTransport.RoundTrip(r)
+		})
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "oci://" + srv.ref,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Transport:       transport,
+			PlainHTTP:       plainHTTP,
+			Registries:      registries,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		return schemes
+	}
+
+	t.Run("should use plain HTTP when requested", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(locate(t, true)).To(HaveEach("http"))
+	})
+
+	t.Run("should use plain HTTP for registries matching a host pattern", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(locate(t, false, locator.Registry{Host: "127.0.0.1:*", PlainHTTP: true})).To(HaveEach("http"))
+	})
+
+	t.Run("should match patterns without a port against the host name", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		g.Expect(locate(t, false, locator.Registry{Host: "127.0.0.*", PlainHTTP: true})).To(HaveEach("http"))
+	})
+
+	t.Run("should fall back to plain HTTP for loopback registries", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		schemes := locate(t, false, locator.Registry{Host: "registry.example.com", PlainHTTP: true})
+		g.Expect(len(schemes)).To(BeNumerically(">", 1))
+		g.Expect(schemes[0]).To(Equal("https"))
+		g.Expect(schemes[1:]).To(HaveEach("http"))
+	})
+
+	t.Run("should accept any certificate from insecure registries", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		plain := newMockOCIRegistry(t, []byte("insecure-chart"))
+		srv := httptest.NewTLSServer(plain.Config.Handler)
+		t.Cleanup(srv.Close)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "oci://" + srv.Listener.Addr().String() + "/test/chart",
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Registries:      []locator.Registry{{Host: "127.0.0.1", Insecure: true}},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should reject invalid host patterns", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "oci://registry.example.com/test/chart",
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Registries:      []locator.Registry{{Host: "[", PlainHTTP: true}},
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("invalid registry host pattern"))
+	})
+}
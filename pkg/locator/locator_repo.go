@@ -7,8 +7,6 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-
-	"sigs.k8s.io/yaml"
 )
 
 var (
@@ -17,16 +15,57 @@ var (
 
 	// ErrEmptyRepoURL is returned when a Repo locator is created without a repository URL.
 	ErrEmptyRepoURL = errors.New("repository URL must not be empty")
+
+	// ErrMissingDigest is returned when RequireDigest is set and the index
+	// entry of the resolved chart version carries no digest.
+	ErrMissingDigest = errors.New("chart version has no digest in repository index")
 )
 
 // Repo resolves charts hosted in a classic Helm HTTP/HTTPS repository.
 type Repo struct {
-	Name        string
-	RepoURL     string
-	Version     string
+	Name    string
+	RepoURL string
+	Version string
+
+	// Credentials authenticate against the origin the index is fetched from,
+	// which is the mirror when Mirrors rewrite RepoURL.
 	Credentials *Credentials
 	CacheDir    string
 	HTTPClient  *http.Client
+
+	// Transport, when set and HTTPClient is nil, is used as the transport of
+	// the HTTP client instead of one built from TLS.
+	Transport http.RoundTripper
+
+	// TLS configures the HTTP client built when neither HTTPClient nor
+	// Transport is set.
+	TLS *TLSConfig
+
+	// Getters fetch URLs with schemes other than http and https, keyed by
+	// scheme.
+	Getters map[string]Getter
+
+	// PassCredentialsAll forwards credentials to chart URLs on other origins.
+	PassCredentialsAll bool
+
+	// RequireDigest refuses index entries without a digest. Entries that do
+	// carry one are always verified against the downloaded archive.
+	RequireDigest bool
+
+	// Offline resolves the chart from CacheDir only, without fetching the
+	// index or the archive. A chart that was never downloaded yields a
+	// *NotCachedError.
+	Offline bool
+
+	// Keyring, when set, requires a "<archive URL>.prov" provenance file
+	// signed by a key in this OpenPGP keyring.
+	Keyring string
+
+	// Mirrors rewrite the index and archive URLs before they are fetched.
+	Mirrors []Mirror
+
+	// Policy decides which index entries are eligible for Version.
+	Policy ResolutionPolicy
 }
 
 // Locate downloads the chart from a Helm repository and returns the local cache path.
@@ -39,75 +78,229 @@ func (r *Repo) Locate(ctx context.Context) (Result, error) {
 		return Result{}, ErrEmptyCacheDir
 	}
 
-	chartURL, err := r.resolveChartURL(ctx)
+	policy, err := r.Policy.compile()
 	if err != nil {
 		return Result{}, err
 	}
 
-	creds, err := r.downloadCredentials(chartURL)
+	key := repoCatalogKey(r.RepoURL, r.Name)
+
+	if r.Offline {
+		return locateCached(r.CacheDir, key, r.Version, SourceRepo, r.Keyring, policy)
+	}
+
+	client, err := r.client()
 	if err != nil {
 		return Result{}, err
 	}
 
-	data, err := httpGet(ctx, r.HTTPClient, chartURL, creds)
+	cv, reason, chartURLs, err := r.resolveChart(ctx, client, policy)
 	if err != nil {
-		return Result{}, fmt.Errorf("unable to download chart: %w", err)
+		return Result{}, err
 	}
 
-	path, err := cacheChart(r.CacheDir, data)
+	result, err := r.fetchArchive(ctx, client, cv, chartURLs)
 	if err != nil {
 		return Result{}, err
 	}
 
-	return Result{Path: path, SourceType: SourceRepo}, nil
+	result.SourceType = SourceRepo
+	result.Version = cv.Version
+	result.Reason = reason
+
+	if r.Keyring != "" {
+		result.Provenance, err = verifyChart(r.Keyring, result.Path, archiveFileName(result.URL), func() ([]byte, error) {
+			return r.fetchProvenance(ctx, client, chartURLs)
+		})
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	entry := catalogEntry{Version: cv.Version, Digest: result.Digest, URL: result.URL, Deprecated: cv.Deprecated}
+	if err := recordChart(r.CacheDir, key, entry); err != nil {
+		return Result{}, err
+	}
+
+	result.readMetadata()
+
+	return result, nil
 }
 
-func (r *Repo) resolveChartURL(ctx context.Context) (string, error) {
-	indexURL := strings.TrimSuffix(r.RepoURL, "/") + "/index.yaml"
+func (r *Repo) client() (*http.Client, error) {
+	client, err := r.httpClient()
+	if err != nil {
+		return nil, err
+	}
 
-	data, err := httpGet(ctx, r.HTTPClient, indexURL, r.Credentials)
+	if len(r.Getters) > 0 {
+		client = withGetters(client, r.Getters, r)
+	}
+
+	return client, nil
+}
+
+func (r *Repo) httpClient() (*http.Client, error) {
+	if r.HTTPClient != nil {
+		return r.HTTPClient, nil
+	}
+
+	if r.Transport != nil {
+		return &http.Client{Timeout: httpTimeout, Transport: r.Transport}, nil
+	}
+
+	if r.TLS == nil {
+		return newHTTPClient(nil), nil
+	}
+
+	tlsConfig, err := r.TLS.build()
 	if err != nil {
-		return "", fmt.Errorf("unable to fetch repository index from %q: %w", indexURL, err)
+		return nil, fmt.Errorf("unable to configure TLS for repo %q: %w", r.RepoURL, err)
 	}
 
-	var idx repoIndex
-	if err := yaml.Unmarshal(data, &idx); err != nil {
-		return "", fmt.Errorf("unable to parse repository index: %w", err)
+	return newHTTPClient(tlsConfig), nil
+}
+
+// resolveChart returns the index entry matching the requested version, the
+// reason it was selected and its download URLs, made absolute and rewritten by
+// Mirrors, in index order.
+func (r *Repo) resolveChart(
+	ctx context.Context,
+	client *http.Client,
+	policy versionPolicy,
+) (*repoChartVersion, ResolutionReason, []string, error) {
+	idx, err := r.loadIndex(ctx, client)
+	if err != nil {
+		return nil, "", nil, err
 	}
 
-	cv, err := idx.resolve(r.Name, r.Version)
+	cv, reason, err := idx.resolve(r.Name, r.Version, policy)
 	if err != nil {
-		return "", fmt.Errorf("unable to find chart %q in repo %q: %w", r.Name, r.RepoURL, err)
+		return nil, "", nil, fmt.Errorf("unable to find chart %q in repo %q: %w", r.Name, r.RepoURL, err)
 	}
 
 	if len(cv.URLs) == 0 {
-		return "", fmt.Errorf("%w: chart %q version %q", ErrNoDownloadURLs, r.Name, cv.Version)
+		return nil, "", nil, fmt.Errorf("%w: chart %q version %q", ErrNoDownloadURLs, r.Name, cv.Version)
+	}
+
+	base, err := url.Parse(strings.TrimSuffix(r.RepoURL, "/") + "/")
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("unable to parse repo URL %q: %w", r.RepoURL, err)
+	}
+
+	chartURLs := make([]string, 0, len(cv.URLs))
+
+	for _, chartURL := range cv.URLs {
+		if u, err := url.Parse(chartURL); err == nil && !u.IsAbs() {
+			chartURL = base.ResolveReference(u).String()
+		}
+
+		chartURLs = append(chartURLs, rewriteURL(r.Mirrors, chartURL))
+	}
+
+	return cv, reason, chartURLs, nil
+}
+
+// fetchArchive returns the cached archive when the index entry carries a
+// digest that is already in the cache, and downloads and verifies it otherwise.
+// The returned Result carries the archive path, digest and URL.
+func (r *Repo) fetchArchive(
+	ctx context.Context,
+	client *http.Client,
+	cv *repoChartVersion,
+	chartURLs []string,
+) (Result, error) {
+	expected := cv.archiveDigest()
+
+	if expected == "" && r.RequireDigest {
+		return Result{}, fmt.Errorf("%w: chart %q version %q", ErrMissingDigest, r.Name, cv.Version)
+	}
+
+	if path, ok := lookupCachedChart(r.CacheDir, expected); ok {
+		return Result{Path: path, Digest: expected, URL: chartURLs[0]}, nil
+	}
+
+	data, chartURL, err := r.download(ctx, client, chartURLs)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to download chart: %w", err)
+	}
+
+	if expected != "" {
+		if err := verifyDigest(expected, data); err != nil {
+			return Result{}, fmt.Errorf("unable to verify chart %q version %q from %q: %w", r.Name, cv.Version, chartURL, err)
+		}
+	}
+
+	path, digest, err := cacheChart(r.CacheDir, data)
+	if err != nil {
+		return Result{}, err
 	}
 
-	chartURL := cv.URLs[0]
+	return Result{Path: path, Digest: digest, URL: chartURL}, nil
+}
+
+func (r *Repo) fetchProvenance(ctx context.Context, client *http.Client, chartURLs []string) ([]byte, error) {
+	provURLs := make([]string, len(chartURLs))
+	for i, chartURL := range chartURLs {
+		provURLs[i] = chartURL + provenanceSuffix
+	}
 
-	if u, err := url.Parse(chartURL); err == nil && !u.IsAbs() {
-		base, parseErr := url.Parse(strings.TrimSuffix(r.RepoURL, "/") + "/")
-		if parseErr != nil {
-			return "", fmt.Errorf("unable to parse repo URL %q: %w", r.RepoURL, parseErr)
+	data, _, err := r.download(ctx, client, provURLs)
+	if err != nil {
+		if errors.Is(err, ErrUnexpectedStatus) {
+			return nil, fmt.Errorf("%w: %w", ErrProvenanceNotFound, err)
 		}
 
-		chartURL = base.ResolveReference(u).String()
+		return nil, fmt.Errorf("unable to download provenance file: %w", err)
 	}
 
-	return chartURL, nil
+	return data, nil
 }
 
-// downloadCredentials returns credentials for the chart download, applying
-// same-origin protection to prevent credential leakage across hosts.
+// download fetches the given URLs in order and returns the body of the first
+// one that succeeds along with its URL. When every URL fails, the errors of
+// all attempts are returned together.
+func (r *Repo) download(ctx context.Context, client *http.Client, urls []string) ([]byte, string, error) {
+	errs := make([]error, 0, len(urls))
+
+	for _, u := range urls {
+		creds, err := r.downloadCredentials(u)
+		if err != nil {
+			return nil, "", err
+		}
+
+		data, err := httpGet(ctx, client, u, creds)
+		if err == nil {
+			return data, u, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, "", err
+		}
+
+		errs = append(errs, err)
+	}
+
+	return nil, "", errors.Join(errs...)
+}
+
+// downloadCredentials returns credentials for the index or chart download,
+// applying same-origin protection to prevent credential leakage across hosts
+// unless PassCredentialsAll is set.
 func (r *Repo) downloadCredentials(chartURL string) (*Credentials, error) {
 	if !r.Credentials.hasAuth() {
 		return nil, nil //nolint:nilnil // nil credentials means no authentication
 	}
 
-	u1, err := url.Parse(r.RepoURL)
+	if r.PassCredentialsAll {
+		return r.Credentials, nil
+	}
+
+	repoURL := rewriteURL(r.Mirrors, r.RepoURL)
+
+	u1, err := url.Parse(repoURL)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse repo URL %q: %w", r.RepoURL, err)
+		return nil, fmt.Errorf("unable to parse repo URL %q: %w", repoURL, err)
 	}
 
 	u2, err := url.Parse(chartURL)
@@ -0,0 +1,128 @@
+package locator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ErrRepositoryNotFound is returned when a "repo/chart" name refers to a
+// repository alias that is not defined in the repositories file.
+var ErrRepositoryNotFound = errors.New("repository not found in repositories file")
+
+// findRepoEntry looks up the repositories file entry that applies to a chart.
+// With an explicit repoURL the entry is matched by URL and a missing entry is
+// not an error; otherwise name must have the form "repo/chart" and the entry
+// is matched by alias. A nil entry with a nil error means no entry applies.
+func findRepoEntry(path string, repoURL string, name string) (*repoEntry, error) {
+	alias, _, isAlias := splitRepoChart(name)
+	if repoURL == "" && !isAlias {
+		return nil, nil //nolint:nilnil // plain chart names do not use the repositories file
+	}
+
+	rf, err := loadRepoFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if repoURL != "" {
+		return rf.byURL(repoURL), nil
+	}
+
+	entry := rf.byName(alias)
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %q (config: %s)", ErrRepositoryNotFound, alias, path)
+	}
+
+	return entry, nil
+}
+
+// RepositoryURL returns the URL of the repository named name in the
+// repositories file at path, the way Helm resolves "@name" and "alias:name"
+// dependency repositories.
+func RepositoryURL(path string, name string) (string, error) {
+	rf, err := loadRepoFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	entry := rf.byName(name)
+	if entry == nil {
+		return "", fmt.Errorf("%w: %q (config: %s)", ErrRepositoryNotFound, name, path)
+	}
+
+	return entry.URL, nil
+}
+
+// splitRepoChart splits a "repo/chart" reference into its alias and chart name.
+func splitRepoChart(name string) (string, string, bool) {
+	if strings.Contains(name, "://") {
+		return "", "", false
+	}
+
+	alias, chartName, ok := strings.Cut(name, "/")
+	if !ok || alias == "" || chartName == "" || strings.Contains(chartName, "/") {
+		return "", "", false
+	}
+
+	return alias, chartName, true
+}
+
+func loadRepoFile(path string) (*repoFile, error) {
+	if path == "" {
+		return &repoFile{}, nil
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // caller controls path
+	if errors.Is(err, os.ErrNotExist) {
+		return &repoFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read repositories file %q: %w", path, err)
+	}
+
+	var rf repoFile
+	if err := yaml.Unmarshal(data, &rf); err != nil {
+		return nil, fmt.Errorf("unable to parse repositories file %q: %w", path, err)
+	}
+
+	return &rf, nil
+}
+
+func (rf *repoFile) byName(name string) *repoEntry {
+	for _, e := range rf.Repositories {
+		if e != nil && e.Name == name {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (rf *repoFile) byURL(repoURL string) *repoEntry {
+	want := strings.TrimSuffix(repoURL, "/")
+
+	for _, e := range rf.Repositories {
+		if e != nil && strings.TrimSuffix(e.URL, "/") == want {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (e *repoEntry) tlsConfig() *TLSConfig {
+	if e.CAFile == "" && e.CertFile == "" && e.KeyFile == "" && !e.InsecureSkipTLSVerify {
+		return nil
+	}
+
+	return &TLSConfig{
+		CAFile:             e.CAFile,
+		CertFile:           e.CertFile,
+		KeyFile:            e.KeyFile,
+		InsecureSkipVerify: e.InsecureSkipTLSVerify,
+	}
+}
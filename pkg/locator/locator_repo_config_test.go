@@ -0,0 +1,313 @@
+package locator_test
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+const repositoriesYAMLTmpl = `apiVersion: ""
+repositories:
+  - name: myrepo
+    url: %s
+    username: %q
+    password: %q
+    caFile: %q
+    insecure_skip_tls_verify: %t
+`
+
+func TestRepoLocator_RepositoryConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should resolve repo alias through repositories file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newChartServer(t, "/mychart-1.2.3.tgz")
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, srv.URL, "", "", "", false))
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			Version:          "1.2.3",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result).To(MatchFields(IgnoreExtras, Fields{
+			"Path":       BeARegularFile(),
+			"SourceType": Equal(locator.SourceRepo),
+		}))
+	})
+
+	t.Run("should use credentials from repositories file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var indexUser, chartUser string
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _, _ := r.BasicAuth()
+
+			switch r.URL.Path {
+			case indexPath:
+				indexUser = user
+				_, _ = w.Write([]byte(repoIndexYAML))
+			case "/mychart-1.0.0.tgz":
+				chartUser = user
+				_, _ = w.Write([]byte(chartData))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, srv.URL, "repo-user", "repo-pass", "", false))
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			Version:          "1.0.0",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(indexUser).To(Equal("repo-user"))
+		g.Expect(chartUser).To(Equal("repo-user"))
+	})
+
+	t.Run("should prefer request credentials over repositories file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var indexUser string
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case indexPath:
+				indexUser, _, _ = r.BasicAuth()
+				_, _ = w.Write([]byte(repoIndexYAML))
+			default:
+				_, _ = w.Write([]byte(chartData))
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, srv.URL, "repo-user", "repo-pass", "", false))
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			Version:          "1.0.0",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{Username: "source-user", Password: "source-pass"}, nil
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(indexUser).To(Equal("source-user"))
+	})
+
+	t.Run("should apply entry settings when RepoURL matches", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var indexUser string
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case indexPath:
+				indexUser, _, _ = r.BasicAuth()
+				_, _ = w.Write([]byte(repoIndexYAML))
+			default:
+				_, _ = w.Write([]byte(chartData))
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, srv.URL+"/", "repo-user", "repo-pass", "", false))
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "mychart",
+			RepoURL:          srv.URL,
+			Version:          "1.0.0",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(indexUser).To(Equal("repo-user"))
+	})
+
+	t.Run("should error on unknown repo alias", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, "https://charts.example.com", "", "", "", false))
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "otherrepo/mychart",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrRepositoryNotFound)).To(BeTrue())
+	})
+
+	t.Run("should treat missing repositories file as empty", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			RepositoryConfig: filepath.Join(t.TempDir(), "missing.yaml"),
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrRepositoryNotFound)).To(BeTrue())
+	})
+
+	t.Run("should error on malformed repositories file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		config := writeRepositoriesFile(t, "repositories: [")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unable to parse repositories file"))
+	})
+
+	t.Run("should look up repository URLs by name", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, "https://charts.example.com", "", "", "", false))
+
+		repoURL, err := locator.RepositoryURL(config, "myrepo")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(repoURL).To(Equal("https://charts.example.com"))
+
+		_, err = locator.RepositoryURL(config, "unknown")
+		g.Expect(errors.Is(err, locator.ErrRepositoryNotFound)).To(BeTrue())
+	})
+}
+
+func TestRepoLocator_RepositoryConfigTLS(t *testing.T) {
+	t.Parallel()
+
+	newTLSChartServer := func(t *testing.T) *httptest.Server {
+		t.Helper()
+
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case indexPath:
+				_, _ = w.Write([]byte(repoIndexYAML))
+			default:
+				_, _ = w.Write([]byte(chartData))
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		return srv
+	}
+
+	t.Run("should fail against untrusted certificate", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSChartServer(t)
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, srv.URL, "", "", "", false))
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			Version:          "1.0.0",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("should trust caFile from repositories file", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSChartServer(t)
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		g.Expect(os.WriteFile(caFile, caPEM, 0600)).To(Succeed())
+
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, srv.URL, "", "", caFile, false))
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			Version:          "1.0.0",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+	})
+
+	t.Run("should honor insecure_skip_tls_verify", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSChartServer(t)
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, srv.URL, "", "", "", true))
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			Version:          "1.0.0",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+	})
+
+	t.Run("should reject CA file without certificates", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSChartServer(t)
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		g.Expect(os.WriteFile(caFile, []byte("not a certificate"), 0600)).To(Succeed())
+
+		config := writeRepositoriesFile(t, fmt.Sprintf(repositoriesYAMLTmpl, srv.URL, "", "", caFile, false))
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:             "myrepo/mychart",
+			Version:          "1.0.0",
+			RepositoryConfig: config,
+			RepositoryCache:  t.TempDir(),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrInvalidCAFile)).To(BeTrue())
+	})
+}
+
+func writeRepositoriesFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "repositories.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
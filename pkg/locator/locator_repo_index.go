@@ -0,0 +1,272 @@
+package locator
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/internal/fsutil"
+)
+
+const indexCacheDir = "index"
+
+// indexMeta records the HTTP validators of a cached index.yaml so that the
+// next fetch can be made conditional.
+type indexMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func (m *indexMeta) hasValidators() bool {
+	return m != nil && (m.ETag != "" || m.LastModified != "")
+}
+
+func (m *indexMeta) matches(other *indexMeta) bool {
+	return m.hasValidators() && other.hasValidators() &&
+		m.URL == other.URL &&
+		m.ETag == other.ETag &&
+		m.LastModified == other.LastModified
+}
+
+// DefaultIndexCacheSize is the number of parsed repository indexes kept in
+// memory by default.
+const DefaultIndexCacheSize = 16
+
+type parsedIndex struct {
+	meta indexMeta
+	idx  *repoIndex
+}
+
+// parsedIndexes holds parsed repository indexes keyed by index URL. It is
+// shared by every Repo in the process so that large indexes are parsed once
+// and reused for as long as the server reports them unchanged.
+//
+//nolint:gochecknoglobals // process-wide cache by design
+var parsedIndexes = &indexCache{
+	size:    DefaultIndexCacheSize,
+	entries: make(map[string]*list.Element),
+	lru:     list.New(),
+}
+
+// SetIndexCacheSize bounds the number of parsed repository indexes kept in
+// memory. The least recently used indexes are dropped first; 0 disables
+// keeping parsed indexes, so that every locate parses the cached index file.
+func SetIndexCacheSize(size int) {
+	parsedIndexes.mu.Lock()
+	defer parsedIndexes.mu.Unlock()
+
+	parsedIndexes.size = size
+	parsedIndexes.evict()
+}
+
+// indexCache is an LRU of parsed indexes. A newer index of the same URL
+// replaces the older one.
+type indexCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+func lookupParsedIndex(meta *indexMeta) *repoIndex {
+	parsedIndexes.mu.Lock()
+	defer parsedIndexes.mu.Unlock()
+
+	e, ok := parsedIndexes.entries[meta.URL]
+	if !ok {
+		return nil
+	}
+
+	entry, _ := e.Value.(*parsedIndex)
+	if !entry.meta.matches(meta) {
+		return nil
+	}
+
+	parsedIndexes.lru.MoveToFront(e)
+
+	return entry.idx
+}
+
+func storeParsedIndex(meta *indexMeta, idx *repoIndex) {
+	if !meta.hasValidators() {
+		return
+	}
+
+	parsedIndexes.mu.Lock()
+	defer parsedIndexes.mu.Unlock()
+
+	if parsedIndexes.size <= 0 {
+		return
+	}
+
+	entry := &parsedIndex{meta: *meta, idx: idx}
+
+	if e, ok := parsedIndexes.entries[meta.URL]; ok {
+		e.Value = entry
+		parsedIndexes.lru.MoveToFront(e)
+
+		return
+	}
+
+	parsedIndexes.entries[meta.URL] = parsedIndexes.lru.PushFront(entry)
+	parsedIndexes.evict()
+}
+
+// evict drops the least recently used indexes beyond the cache size. The
+// caller must hold mu.
+func (c *indexCache) evict() {
+	for c.lru.Len() > max(c.size, 0) {
+		e := c.lru.Back()
+		entry, _ := e.Value.(*parsedIndex)
+
+		c.lru.Remove(e)
+		delete(c.entries, entry.meta.URL)
+	}
+}
+
+// parseRepoIndex parses an index.yaml and sorts every entry newest first.
+// The result is never mutated afterwards, so it is safe to share.
+func parseRepoIndex(data []byte) (*repoIndex, error) {
+	var idx repoIndex
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("unable to parse repository index: %w", err)
+	}
+
+	for name := range idx.Entries {
+		sort.Sort(idx.Entries[name])
+	}
+
+	return &idx, nil
+}
+
+// indexCachePaths returns the cache locations of the index body and its
+// metadata, keyed by the index URL.
+func indexCachePaths(cacheDir string, indexURL string) (string, string) {
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(indexURL)))
+	dir := filepath.Join(cacheDir, indexCacheDir)
+
+	return filepath.Join(dir, key+".yaml"), filepath.Join(dir, key+".json")
+}
+
+// loadIndex returns the parsed repository index, revalidating any cached copy
+// with If-None-Match / If-Modified-Since and reusing it on 304 Not Modified.
+func (r *Repo) loadIndex(ctx context.Context, client *http.Client) (*repoIndex, error) {
+	indexURL := rewriteURL(r.Mirrors, strings.TrimSuffix(r.RepoURL, "/")+"/index.yaml")
+	indexPath, metaPath := indexCachePaths(r.CacheDir, indexURL)
+
+	cached := readIndexMeta(metaPath, indexURL)
+
+	header := http.Header{}
+	if cached.hasValidators() && fileExists(indexPath) {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	creds, err := r.downloadCredentials(indexURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpFetch(ctx, client, indexURL, creds, header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch repository index from %q: %w", indexURL, err)
+	}
+
+	if resp.NotModified {
+		return loadCachedIndex(indexPath, cached)
+	}
+
+	idx, err := parseRepoIndex(resp.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &indexMeta{
+		URL:          indexURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	if err := writeIndexCache(indexPath, metaPath, resp.Data, meta); err != nil {
+		return nil, err
+	}
+
+	storeParsedIndex(meta, idx)
+
+	return idx, nil
+}
+
+func loadCachedIndex(indexPath string, meta *indexMeta) (*repoIndex, error) {
+	if idx := lookupParsedIndex(meta); idx != nil {
+		return idx, nil
+	}
+
+	data, err := os.ReadFile(indexPath) //nolint:gosec // path is derived from the cache directory
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cached repository index: %w", err)
+	}
+
+	idx, err := parseRepoIndex(data)
+	if err != nil {
+		return nil, err
+	}
+
+	storeParsedIndex(meta, idx)
+
+	return idx, nil
+}
+
+// readIndexMeta returns the cached validators for indexURL, or nil when there
+// are none. Unreadable metadata is ignored; it only costs a full download.
+func readIndexMeta(metaPath string, indexURL string) *indexMeta {
+	data, err := os.ReadFile(metaPath) //nolint:gosec // path is derived from the cache directory
+	if err != nil {
+		return nil
+	}
+
+	var meta indexMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.URL != indexURL {
+		return nil
+	}
+
+	return &meta
+}
+
+func writeIndexCache(indexPath string, metaPath string, data []byte, meta *indexMeta) error {
+	metaData, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("unable to encode repository index metadata: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(indexPath, data); err != nil {
+		return fmt.Errorf("unable to cache repository index: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(metaPath, metaData); err != nil {
+		return fmt.Errorf("unable to cache repository index metadata: %w", err)
+	}
+
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+
+	return err == nil
+}
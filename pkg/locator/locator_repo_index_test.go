@@ -0,0 +1,178 @@
+package locator_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRepoLocator_IndexCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should revalidate cached index with ETag", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, downloads, notModified := newValidatingChartServer(t, `"index-v1"`, "")
+		cacheDir := t.TempDir()
+
+		for range 3 {
+			result, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         "1.2.3",
+				RepositoryCache: cacheDir,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Path).To(BeARegularFile())
+		}
+
+		g.Expect(downloads.Load()).To(Equal(int32(1)))
+		g.Expect(notModified.Load()).To(Equal(int32(2)))
+
+		cached, err := filepath.Glob(filepath.Join(cacheDir, "index", "*.yaml"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cached).To(HaveLen(1))
+	})
+
+	t.Run("should revalidate cached index with Last-Modified", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, downloads, notModified := newValidatingChartServer(t, "", time.Now().UTC().Format(http.TimeFormat))
+		cacheDir := t.TempDir()
+
+		for range 2 {
+			_, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         "1.2.3",
+				RepositoryCache: cacheDir,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+
+		g.Expect(downloads.Load()).To(Equal(int32(1)))
+		g.Expect(notModified.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("should not revalidate when the cache directory is empty", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, downloads, _ := newValidatingChartServer(t, `"index-shared"`, "")
+
+		for range 2 {
+			_, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         "1.2.3",
+				RepositoryCache: t.TempDir(),
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+
+		g.Expect(downloads.Load()).To(Equal(int32(2)))
+	})
+
+	t.Run("should download index every time without validators", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, downloads, notModified := newValidatingChartServer(t, "", "")
+		cacheDir := t.TempDir()
+
+		for range 2 {
+			_, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         "1.2.3",
+				RepositoryCache: cacheDir,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+
+		g.Expect(downloads.Load()).To(Equal(int32(2)))
+		g.Expect(notModified.Load()).To(BeZero())
+	})
+
+	t.Run("should fall back to a full download when the cached index is missing", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, downloads, notModified := newValidatingChartServer(t, `"index-v2"`, "")
+		cacheDir := t.TempDir()
+
+		req := &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.2.3",
+			RepositoryCache: cacheDir,
+		}
+
+		_, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		cached, err := filepath.Glob(filepath.Join(cacheDir, "index", "*.yaml"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cached).To(HaveLen(1))
+		g.Expect(os.Remove(cached[0])).To(Succeed())
+
+		_, err = locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(downloads.Load()).To(Equal(int32(2)))
+		g.Expect(notModified.Load()).To(BeZero())
+	})
+}
+
+// newValidatingChartServer serves repoIndexYAML with the given validators and
+// answers matching conditional requests with 304. It returns counters for full
+// index downloads and 304 responses.
+func newValidatingChartServer(
+	t *testing.T,
+	etag string,
+	lastModified string,
+) (*httptest.Server, *atomic.Int32, *atomic.Int32) {
+	t.Helper()
+
+	var downloads, notModified atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != indexPath {
+			_, _ = w.Write([]byte(chartData))
+
+			return
+		}
+
+		if (etag != "" && r.Header.Get("If-None-Match") == etag) ||
+			(lastModified != "" && r.Header.Get("If-Modified-Since") == lastModified) {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+
+		if lastModified != "" {
+			w.Header().Set("Last-Modified", lastModified)
+		}
+
+		downloads.Add(1)
+		_, _ = w.Write([]byte(repoIndexYAML))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &downloads, &notModified
+}
@@ -1,5 +1,15 @@
 package locator
 
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	godigest "github.com/opencontainers/go-digest"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"sigs.k8s.io/yaml"
+)
+
 // SourceType indicates how a chart was resolved.
 type SourceType string
 
@@ -10,10 +20,100 @@ const (
 	SourceOCI SourceType = "oci"
 	// SourceRepo indicates the chart was downloaded from a Helm HTTP repository.
 	SourceRepo SourceType = "repo"
+	// SourceURL indicates the chart was downloaded from a direct archive URL.
+	SourceURL SourceType = "url"
+	// SourceGit indicates the chart was checked out from a git repository.
+	SourceGit SourceType = "git"
+	// SourceFS indicates the chart was read from an fs.FS supplied by the
+	// caller rather than located; Path is the chart root within that FS.
+	SourceFS SourceType = "fs"
+	// SourceLoaded indicates the caller supplied an already-loaded chart.
+	SourceLoaded SourceType = "loaded"
 )
 
 // Result is the outcome of a Locate call.
 type Result struct {
 	Path       string
 	SourceType SourceType
+
+	// Version is the chart version that was selected. It is empty for local
+	// charts and digest-pinned OCI refs.
+	Version string
+
+	// Reason tells why Version was selected. It is empty for local charts.
+	Reason ResolutionReason
+
+	// Digest is the archive digest in "sha256:<hex>" form. It is empty for
+	// unpacked local chart directories. For charts read from an fs.FS or
+	// supplied already loaded, it is a digest of the chart contents.
+	Digest string
+
+	// URL is where the archive was fetched from: the download URL for
+	// repository and archive URL charts, the "oci://registry/repo:tag" or
+	// "oci://registry/repo@digest" reference for OCI charts, or the "git+"
+	// reference pinned to Commit for git charts. It is empty for local
+	// charts. When the archive was served from the cache, it is the URL it
+	// would have been downloaded from.
+	URL string
+
+	// Commit is the commit a git chart was checked out at. It is empty for
+	// other sources.
+	Commit string
+
+	// Metadata is the parsed Chart.yaml. It is nil when the chart could not
+	// be read, in which case loading the chart reports the actual error.
+	Metadata *chart.Metadata
+
+	// Provenance is set when the chart was verified against its provenance file.
+	Provenance *Verification
+}
+
+// readMetadata sets Metadata from the chart at Path, if it can be read.
+func (r *Result) readMetadata() {
+	meta, err := chartMetadata(r.Path)
+	if err != nil {
+		return
+	}
+
+	r.Metadata = &meta
+}
+
+// chartMetadata reads Chart.yaml from a chart archive or directory.
+func chartMetadata(path string) (chart.Metadata, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return chart.Metadata{}, fmt.Errorf("unable to stat chart %q: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		return ExtractChartMeta(path)
+	}
+
+	data, err := os.ReadFile(filepath.Join(path, "Chart.yaml")) //nolint:gosec // path is the located chart
+	if err != nil {
+		return chart.Metadata{}, fmt.Errorf("unable to read Chart.yaml: %w", err)
+	}
+
+	var meta chart.Metadata
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return chart.Metadata{}, fmt.Errorf("unable to parse Chart.yaml: %w", err)
+	}
+
+	return meta, nil
+}
+
+// fileDigest returns the sha256 digest of the file at path in "sha256:<hex>" form.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path) //nolint:gosec // path is the located chart
+	if err != nil {
+		return "", fmt.Errorf("unable to open %q: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	dgst, err := godigest.FromReader(f)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash %q: %w", path, err)
+	}
+
+	return dgst.String(), nil
 }
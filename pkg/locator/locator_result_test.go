@@ -0,0 +1,169 @@
+package locator_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+func TestLocate_ResultDetails(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should report version, digest, URL and metadata for repo charts", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		archive := readChartArchive(t, "mychart", "1.2.3")
+		srv := newArchiveChartServer(t, archive)
+		cacheDir := t.TempDir()
+
+		req := &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "~1.2",
+			RepositoryCache: cacheDir,
+		}
+
+		expected := MatchFields(IgnoreExtras, Fields{
+			"Version":  Equal("1.2.3"),
+			"Reason":   Equal(locator.ReasonConstraint),
+			"Digest":   Equal(digest.FromBytes(archive).String()),
+			"URL":      Equal(srv.URL + "/mychart-1.2.3.tgz"),
+			"Metadata": PointTo(MatchFields(IgnoreExtras, Fields{"Name": Equal("mychart")})),
+		})
+
+		result, err := locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result).To(expected)
+
+		srv.Close()
+		req.Offline = true
+
+		result, err = locator.Locate(t.Context(), req)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result).To(expected)
+	})
+
+	t.Run("should report the OCI reference for tags and digests", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		archive := readChartArchive(t, "chart", "1.0.0")
+		srv := newMockOCIRegistry(t, archive)
+
+		result, err := (&locator.OCI{
+			Ref:       "oci://" + srv.ref,
+			Version:   "1.0.0",
+			CacheDir:  t.TempDir(),
+			PlainHTTP: true,
+		}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result).To(MatchFields(IgnoreExtras, Fields{
+			"URL":      Equal("oci://" + srv.ref + ":1.0.0"),
+			"Digest":   Equal(digest.FromBytes(archive).String()),
+			"Metadata": PointTo(MatchFields(IgnoreExtras, Fields{"Version": Equal("1.0.0")})),
+		}))
+
+		pinned := fmt.Sprintf("oci://%s@%s", srv.ref, srv.manifestDigest)
+
+		result, err = (&locator.OCI{
+			Ref:       pinned,
+			CacheDir:  t.TempDir(),
+			PlainHTTP: true,
+		}).Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.URL).To(Equal(pinned))
+	})
+
+	t.Run("should report metadata for local chart directories", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		dir := t.TempDir()
+		g.Expect(os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: local\nversion: 0.3.0\n"), 0600)).
+			To(Succeed())
+
+		result, err := locator.Locate(t.Context(), &locator.Request{Name: dir})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Digest).To(BeEmpty())
+		g.Expect(result.Metadata).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"Name":    Equal("local"),
+			"Version": Equal("0.3.0"),
+		})))
+	})
+
+	t.Run("should report the digest of local chart archives", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		path := buildChartArchive(t, map[string]string{"local/Chart.yaml": "name: local\nversion: 0.3.0"})
+
+		data, err := os.ReadFile(path)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		result, err := locator.Locate(t.Context(), &locator.Request{Name: path})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Digest).To(Equal(digest.FromBytes(data).String()))
+		g.Expect(result.Metadata).ToNot(BeNil())
+	})
+
+	t.Run("should leave metadata empty for unreadable archives", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newChartServer(t, "/mychart-1.2.3.tgz")
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.2.3",
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Metadata).To(BeNil())
+	})
+}
+
+func readChartArchive(t *testing.T, name string, version string) []byte {
+	t.Helper()
+
+	path := buildChartArchive(t, map[string]string{
+		name + "/Chart.yaml": fmt.Sprintf("name: %s\nversion: %q", name, version),
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// newArchiveChartServer serves repoIndexYAML and the given archive for every
+// other path.
+func newArchiveChartServer(t *testing.T, archive []byte) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == indexPath {
+			_, _ = w.Write([]byte(repoIndexYAML))
+
+			return
+		}
+
+		_, _ = w.Write(archive)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
@@ -0,0 +1,316 @@
+package locator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+const (
+	defaultRetryInitialBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff     = 30 * time.Second
+)
+
+// defaultRetryableStatus are the HTTP status codes retried unless
+// RetryPolicy.RetryableStatus is set.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var defaultRetryableStatus = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// HTTPStatusError is returned when a repository answers with a status other
+// than 200 OK. It matches ErrUnexpectedStatus.
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+
+	// RetryAfter is the delay requested by a Retry-After header, or zero.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s: %d from %s", ErrUnexpectedStatus, e.StatusCode, e.URL)
+}
+
+func (e *HTTPStatusError) Unwrap() error {
+	return ErrUnexpectedStatus
+}
+
+// RetryError is returned when Locate gives up after retrying. It reports the
+// number of attempts made and wraps the error of the last one.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryError reports whether err or any error in its chain is a *RetryError.
+func IsRetryError(err error) bool {
+	var target *RetryError
+
+	return errors.As(err, &target)
+}
+
+// RetryPolicy decides how often and how fast Locate retries after a transient
+// failure, such as a 502 from a registry or a reset connection.
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	Attempts int
+
+	// InitialBackoff is the delay before the first retry. It doubles with
+	// every further retry up to MaxBackoff. Defaults: 500ms and 30s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Jitter randomizes each delay by up to this fraction in either
+	// direction, e.g. 0.2 for ±20%. Zero disables jitter.
+	Jitter float64
+
+	// RetryableStatus lists the HTTP status codes worth retrying. Default:
+	// 408, 429, 500, 502, 503 and 504.
+	RetryableStatus []int
+
+	// Retryable, when set, decides which errors are retried instead of the
+	// default: the status codes above, timeouts, refused or reset connections
+	// and truncated responses.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy returns a policy making up to three attempts with
+// exponential backoff starting at 500ms and ±20% jitter.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{Attempts: 3, Jitter: 0.2}
+}
+
+// do calls fn until it succeeds, fails with an error that is not retryable,
+// runs out of attempts or would outlive the context deadline. A Retry-After
+// header replaces the backoff, capped at MaxBackoff.
+func (p *RetryPolicy) do(ctx context.Context, fn func() (Result, error)) (Result, error) {
+	if !p.retries() {
+		return fn()
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil {
+			return result, nil
+		}
+
+		retryable := p.retryable(err)
+		if !retryable && attempt == 1 {
+			return Result{}, err
+		}
+
+		if !retryable || attempt == p.Attempts || ctx.Err() != nil {
+			return Result{}, &RetryError{Attempts: attempt, Err: err}
+		}
+
+		wait := p.backoff(attempt, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return Result{}, &RetryError{Attempts: attempt, Err: err}
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return Result{}, &RetryError{Attempts: attempt, Err: errors.Join(err, ctx.Err())}
+		case <-timer.C:
+		}
+	}
+}
+
+// retries reports whether the policy makes more than one attempt.
+func (p *RetryPolicy) retries() bool {
+	return p != nil && p.Attempts >= 2
+}
+
+// backoff returns the delay after the given failed attempt.
+func (p *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	if retryAfter := retryAfterDelay(err); retryAfter > 0 {
+		return min(retryAfter, maxBackoff)
+	}
+
+	wait := p.InitialBackoff
+	if wait <= 0 {
+		wait = defaultRetryInitialBackoff
+	}
+
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+
+	if p.Jitter > 0 {
+		//nolint:gosec // jitter needs no cryptographic randomness
+		wait = time.Duration(float64(wait) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+
+	return min(wait, maxBackoff)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	statuses := p.RetryableStatus
+	if statuses == nil {
+		statuses = defaultRetryableStatus
+	}
+
+	if code, ok := statusCode(err); ok {
+		return slices.Contains(statuses, code)
+	}
+
+	if IsCertificateError(err) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// statusCode returns the HTTP status of a repository or registry error.
+func statusCode(err error) (int, bool) {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+
+	var respErr *errcode.ErrorResponse
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode, true
+	}
+
+	return 0, false
+}
+
+// retryAfterDelay returns the Retry-After delay reported with err, or zero.
+func retryAfterDelay(err error) time.Duration {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+
+	var retryAfterErr *retryAfterError
+	if errors.As(err, &retryAfterErr) {
+		return retryAfterErr.delay
+	}
+
+	return 0
+}
+
+// retryAfterTransport records the Retry-After delay of the last 429 or 503
+// registry response, which errcode.ErrorResponse does not carry.
+type retryAfterTransport struct {
+	base  http.RoundTripper
+	delay atomic.Int64
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // transports return errors as is
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		t.delay.Store(int64(parseRetryAfter(resp.Header.Get("Retry-After"))))
+	}
+
+	return resp, nil
+}
+
+// wrap attaches the recorded Retry-After delay to a registry error answered
+// with 429 or 503. A nil transport returns err unchanged.
+func (t *retryAfterTransport) wrap(err error) error {
+	if t == nil {
+		return err
+	}
+
+	code, ok := statusCode(err)
+	if !ok || (code != http.StatusTooManyRequests && code != http.StatusServiceUnavailable) {
+		return err
+	}
+
+	delay := time.Duration(t.delay.Load())
+	if delay <= 0 {
+		return err
+	}
+
+	return &retryAfterError{err: err, delay: delay}
+}
+
+// retryAfterError carries the Retry-After delay of a registry error.
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// parseRetryAfter returns the delay of a Retry-After header given in seconds
+// or as an HTTP date, or zero.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+
+	return 0
+}
@@ -0,0 +1,325 @@
+package locator_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLocate_Retry(t *testing.T) {
+	t.Parallel()
+
+	fastRetry := func(attempts int) *locator.RetryPolicy {
+		return &locator.RetryPolicy{Attempts: attempts, InitialBackoff: time.Millisecond}
+	}
+
+	t.Run("should retry a transient status until the download succeeds", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyChartServer(t, 2, http.StatusBadGateway, "")
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Retry:           fastRetry(3),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+		g.Expect(requests.Load()).To(Equal(int32(3)))
+	})
+
+	t.Run("should report the attempts after giving up", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyChartServer(t, 10, http.StatusServiceUnavailable, "")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Retry:           fastRetry(3),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(requests.Load()).To(Equal(int32(3)))
+
+		var retryErr *locator.RetryError
+		g.Expect(errors.As(err, &retryErr)).To(BeTrue())
+		g.Expect(retryErr.Attempts).To(Equal(3))
+
+		var statusErr *locator.HTTPStatusError
+		g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+		g.Expect(statusErr.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		g.Expect(errors.Is(err, locator.ErrUnexpectedStatus)).To(BeTrue())
+	})
+
+	t.Run("should not retry permanent failures", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyChartServer(t, 10, http.StatusNotFound, "")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Retry:           fastRetry(3),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsRetryError(err)).To(BeFalse())
+		g.Expect(errors.Is(err, locator.ErrUnexpectedStatus)).To(BeTrue())
+		g.Expect(requests.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("should not retry without a policy", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyChartServer(t, 1, http.StatusBadGateway, "")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsRetryError(err)).To(BeFalse())
+		g.Expect(requests.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("should honor Retry-After up to the maximum backoff", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyChartServer(t, 1, http.StatusTooManyRequests, "3600")
+
+		start := time.Now()
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Retry: &locator.RetryPolicy{
+				Attempts:       2,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     50 * time.Millisecond,
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		g.Expect(requests.Load()).To(Equal(int32(2)))
+	})
+
+	t.Run("should stop when the backoff outlives the context deadline", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyChartServer(t, 10, http.StatusBadGateway, "")
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		_, err := locator.Locate(ctx, &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Retry:           &locator.RetryPolicy{Attempts: 5, InitialBackoff: time.Minute},
+		})
+		g.Expect(locator.IsRetryError(err)).To(BeTrue())
+		g.Expect(requests.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("should retry only the configured status codes", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyChartServer(t, 1, http.StatusNotFound, "")
+
+		policy := fastRetry(2)
+		policy.RetryableStatus = []int{http.StatusNotFound}
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Retry:           policy,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(requests.Load()).To(Equal(int32(2)))
+	})
+
+	t.Run("should let a custom classifier decide", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyChartServer(t, 10, http.StatusBadGateway, "")
+
+		var classified atomic.Int32
+
+		policy := fastRetry(3)
+		policy.Retryable = func(err error) bool {
+			classified.Add(1)
+
+			return !errors.Is(err, locator.ErrUnexpectedStatus)
+		}
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Retry:           policy,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsRetryError(err)).To(BeFalse())
+		g.Expect(classified.Load()).To(Equal(int32(1)))
+		g.Expect(requests.Load()).To(Equal(int32(1)))
+	})
+
+	t.Run("should retry registry failures", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, failures := newFlakyRegistry(t, 1, http.StatusBadGateway, "")
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "oci://" + srv.Listener.Addr().String() + "/test/chart",
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			PlainHTTP:       true,
+			Retry:           fastRetry(2),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+		g.Expect(failures.Load()).To(BeNumerically(">=", 2))
+	})
+
+	t.Run("should not retry registry requests twice", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, requests := newFlakyRegistry(t, 10, http.StatusServiceUnavailable, "")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "oci://" + srv.Listener.Addr().String() + "/test/chart",
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			PlainHTTP:       true,
+			Retry:           fastRetry(2),
+		})
+		g.Expect(locator.IsRetryError(err)).To(BeTrue())
+		g.Expect(requests.Load()).To(Equal(int32(2)))
+	})
+
+	t.Run("should honor Retry-After of registry responses", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newFlakyRegistry(t, 1, http.StatusTooManyRequests, "3600")
+
+		start := time.Now()
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "oci://" + srv.Listener.Addr().String() + "/test/chart",
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			PlainHTTP:       true,
+			Retry: &locator.RetryPolicy{
+				Attempts:       2,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     50 * time.Millisecond,
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+	})
+}
+
+// newFlakyRegistry serves a mock OCI registry holding "test/chart:1.0.0", but
+// answers the first failures manifest requests with status and the given
+// Retry-After header. It returns the server and the number of manifest
+// requests received.
+func newFlakyRegistry(
+	t *testing.T,
+	failures int32,
+	status int,
+	retryAfter string,
+) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	registry := newMockOCIRegistry(t, []byte("retry-oci-chart"))
+
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/manifests/") && requests.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+
+			w.WriteHeader(status)
+
+			return
+		}
+
+		registry.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+// newFlakyChartServer serves repoIndexYAML and chartData, but answers the first
+// failures index requests with status and the given Retry-After header. It
+// returns the server and the number of index requests received.
+func newFlakyChartServer(
+	t *testing.T,
+	failures int32,
+	status int,
+	retryAfter string,
+) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != indexPath {
+			_, _ = w.Write([]byte(chartData))
+
+			return
+		}
+
+		if requests.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+
+			w.WriteHeader(status)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(repoIndexYAML))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
@@ -0,0 +1,147 @@
+package locator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// LocatorFactory builds the Locator for a chart reference whose scheme it was
+// registered for in Request.Locators.
+type LocatorFactory func(ctx context.Context, req *SchemeRequest) (Locator, error)
+
+// SchemeRequest describes a chart reference handed to a LocatorFactory.
+type SchemeRequest struct {
+	// Scheme is the lower-cased scheme the factory was selected by.
+	Scheme string
+
+	// Name, RepoURL and Version are taken from the Request as is. The scheme
+	// belongs to RepoURL when it is set and to Name otherwise.
+	Name    string
+	RepoURL string
+	Version string
+
+	// Credentials are resolved the same way as for repositories: the
+	// Request's own, then the CredentialProviders by host. Nil means none.
+	Credentials *Credentials
+
+	// CacheDir is the repository cache directory. Locators should store
+	// downloaded archives below it.
+	CacheDir string
+
+	// Offline forbids network access; the locator should resolve the chart
+	// from CacheDir only.
+	Offline bool
+
+	// Keyring is set when the chart must be verified. The locator reports
+	// the signer in Result.Provenance, for example by calling VerifyChart on
+	// the archive and its provenance file; results without one are refused.
+	Keyring string
+
+	// TLS, HTTPClient, Transport and Mirrors are taken from the Request as
+	// is, for locators that talk HTTP themselves. HTTPClient, or else
+	// Transport, replaces TLS the same way it does for the built-in locators.
+	TLS        *TLSConfig
+	HTTPClient *http.Client
+	Transport  http.RoundTripper
+	Mirrors    []Mirror
+
+	// Retry is the Request's retry policy. Locate already retries the whole
+	// Locate call of the locator; locators may use it for retries of their own.
+	Retry *RetryPolicy
+}
+
+// schemeLocator post-processes the Result of a registered Locator.
+type schemeLocator struct {
+	Locator
+
+	scheme  string
+	keyring string
+}
+
+// Locate calls the registered locator, refuses unverified charts when a
+// keyring is required and fills SourceType and Metadata when left empty.
+func (l *schemeLocator) Locate(ctx context.Context) (Result, error) {
+	result, err := l.Locator.Locate(ctx)
+	if err != nil {
+		return Result{}, fmt.Errorf("%s locator: %w", l.scheme, err)
+	}
+
+	if l.keyring != "" && result.Provenance == nil {
+		return Result{}, fmt.Errorf("%w: %s locator did not verify the chart", ErrProvenanceNotFound, l.scheme)
+	}
+
+	if result.SourceType == "" {
+		result.SourceType = SourceType(l.scheme)
+	}
+
+	if result.Metadata == nil && result.Path != "" {
+		result.readMetadata()
+	}
+
+	return result, nil
+}
+
+// newSchemeLocator returns the registered locator for the scheme of the
+// request, or nil when none is registered for it.
+func newSchemeLocator(
+	ctx context.Context,
+	req *Request,
+	name string,
+	version string,
+	creds *Credentials,
+	keyring string,
+) (Locator, error) {
+	ref := req.RepoURL
+	if ref == "" {
+		ref = name
+	}
+
+	scheme := refScheme(ref)
+
+	factory, ok := req.Locators[scheme]
+	if !ok || factory == nil {
+		return nil, nil //nolint:nilnil // no registered locator means a built-in one
+	}
+
+	if !req.Offline && !creds.hasAuth() {
+		c, err := providerCredentials(ctx, req.CredentialProviders, urlHost(ref))
+		if err != nil {
+			return nil, err
+		}
+
+		creds = c
+	}
+
+	l, err := factory(ctx, &SchemeRequest{
+		Scheme:      scheme,
+		Name:        name,
+		RepoURL:     req.RepoURL,
+		Version:     version,
+		Credentials: creds,
+		CacheDir:    req.RepositoryCache,
+		Offline:     req.Offline,
+		Keyring:     keyring,
+		TLS:         req.TLS,
+		HTTPClient:  req.HTTPClient,
+		Transport:   req.Transport,
+		Mirrors:     req.Mirrors,
+		Retry:       req.Retry,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s locator: %w", scheme, err)
+	}
+
+	return &schemeLocator{Locator: l, scheme: scheme, keyring: keyring}, nil
+}
+
+// refScheme returns the lower-cased scheme of ref, or "" when it has none.
+func refScheme(ref string) string {
+	scheme, _, ok := strings.Cut(ref, "://")
+	if !ok {
+		return ""
+	}
+
+	return strings.ToLower(scheme)
+}
@@ -0,0 +1,236 @@
+package locator_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
+
+	. "github.com/onsi/gomega"
+)
+
+// staticLocator returns result from Locate.
+type staticLocator struct {
+	result locator.Result
+	err    error
+}
+
+func (l *staticLocator) Locate(_ context.Context) (locator.Result, error) {
+	return l.result, l.err
+}
+
+func TestLocate_Locators(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should use the locator registered for the scheme of the name", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		archive := buildChartArchive(t, map[string]string{
+			"mychart/Chart.yaml": "name: mychart\nversion: 1.0.0",
+		})
+		cacheDir := t.TempDir()
+
+		var got *locator.SchemeRequest
+
+		tlsConfig := &locator.TLSConfig{InsecureSkipVerify: true}
+		client := &http.Client{}
+		transport := &http.Transport{}
+		mirrors := []locator.Mirror{{Prefix: "s3://bucket/", Replacement: "s3://mirror/"}}
+		retry := locator.DefaultRetryPolicy()
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "S3://bucket/charts/mychart",
+			Version:         "1.0.0",
+			RepositoryCache: cacheDir,
+			TLS:             tlsConfig,
+			HTTPClient:      client,
+			Transport:       transport,
+			Mirrors:         mirrors,
+			Retry:           &retry,
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{Username: "user", Password: "pass"}, nil
+			},
+			Locators: map[string]locator.LocatorFactory{
+				"s3": func(_ context.Context, req *locator.SchemeRequest) (locator.Locator, error) {
+					got = req
+
+					return &staticLocator{result: locator.Result{Path: archive, Version: "1.0.0"}}, nil
+				},
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(got).To(Equal(&locator.SchemeRequest{
+			Scheme:      "s3",
+			Name:        "S3://bucket/charts/mychart",
+			Version:     "1.0.0",
+			Credentials: &locator.Credentials{Username: "user", Password: "pass"},
+			CacheDir:    cacheDir,
+			TLS:         tlsConfig,
+			HTTPClient:  client,
+			Transport:   transport,
+			Mirrors:     mirrors,
+			Retry:       &retry,
+		}))
+		g.Expect(result.SourceType).To(Equal(locator.SourceType("s3")))
+		g.Expect(result.Metadata).ToNot(BeNil())
+		g.Expect(result.Metadata.Name).To(Equal("mychart"))
+	})
+
+	t.Run("should use the scheme of the repository URL", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var host string
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         "gs://bucket/charts",
+			RepositoryCache: t.TempDir(),
+			CredentialProviders: []locator.CredentialProvider{
+				locator.CredentialProviderFunc(func(_ context.Context, h string) (*locator.Credentials, error) {
+					host = h
+
+					return &locator.Credentials{BearerToken: "token"}, nil
+				}),
+			},
+			Locators: map[string]locator.LocatorFactory{
+				"gs": func(_ context.Context, req *locator.SchemeRequest) (locator.Locator, error) {
+					g.Expect(req.RepoURL).To(Equal("gs://bucket/charts"))
+					g.Expect(req.Name).To(Equal("mychart"))
+					g.Expect(req.Credentials).To(Equal(&locator.Credentials{BearerToken: "token"}))
+
+					return &staticLocator{result: locator.Result{Path: "/charts/mychart", SourceType: "gcs"}}, nil
+				},
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(host).To(Equal("bucket"))
+	})
+
+	t.Run("should take precedence over built-in schemes", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "oci://registry.example.com/charts/mychart",
+			RepositoryCache: t.TempDir(),
+			Locators: map[string]locator.LocatorFactory{
+				"oci": func(_ context.Context, _ *locator.SchemeRequest) (locator.Locator, error) {
+					return &staticLocator{result: locator.Result{Path: "/charts/mychart"}}, nil
+				},
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.SourceType).To(Equal(locator.SourceType("oci")))
+	})
+
+	t.Run("should report factory and locator errors", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		errBoom := errors.New("boom")
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name: "s3://bucket/mychart",
+			Locators: map[string]locator.LocatorFactory{
+				"s3": func(_ context.Context, _ *locator.SchemeRequest) (locator.Locator, error) {
+					return nil, errBoom
+				},
+			},
+		})
+		g.Expect(errors.Is(err, errBoom)).To(BeTrue())
+
+		_, err = locator.Locate(t.Context(), &locator.Request{
+			Name: "s3://bucket/mychart",
+			Locators: map[string]locator.LocatorFactory{
+				"s3": func(_ context.Context, _ *locator.SchemeRequest) (locator.Locator, error) {
+					return &staticLocator{err: errBoom}, nil
+				},
+			},
+		})
+		g.Expect(errors.Is(err, errBoom)).To(BeTrue())
+	})
+
+	t.Run("should refuse unverified charts when verification is required", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var keyring string
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:    "s3://bucket/mychart",
+			Verify:  true,
+			Keyring: "/keys/pubring.gpg",
+			Locators: map[string]locator.LocatorFactory{
+				"s3": func(_ context.Context, req *locator.SchemeRequest) (locator.Locator, error) {
+					keyring = req.Keyring
+
+					return &staticLocator{result: locator.Result{Path: "/charts/mychart"}}, nil
+				},
+			},
+		})
+		g.Expect(errors.Is(err, locator.ErrProvenanceNotFound)).To(BeTrue())
+		g.Expect(keyring).To(Equal("/keys/pubring.gpg"))
+	})
+
+	t.Run("should accept charts verified with VerifyChart", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		archive := buildChartArchive(t, map[string]string{
+			"mychart/Chart.yaml": "name: mychart\nversion: 1.0.0",
+		})
+		data, err := os.ReadFile(archive)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		signer := newTestSigner(t, "Chart Signer", "signer@example.com")
+		prov := signProvenance(t, signer, "mychart-1.0.0.tgz", data)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:    "s3://bucket/mychart",
+			Verify:  true,
+			Keyring: writeKeyring(t, signer),
+			Locators: map[string]locator.LocatorFactory{
+				"s3": func(_ context.Context, req *locator.SchemeRequest) (locator.Locator, error) {
+					verification, err := locator.VerifyChart(req.Keyring, archive, "mychart-1.0.0.tgz", prov)
+					if err != nil {
+						return nil, err
+					}
+
+					return &staticLocator{result: locator.Result{Path: archive, Provenance: verification}}, nil
+				},
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Provenance).ToNot(BeNil())
+		g.Expect(result.Provenance.SignedBy).To(Equal("Chart Signer <signer@example.com>"))
+		g.Expect(archive + ".prov").To(BeARegularFile())
+	})
+
+	t.Run("should fall back to built-in locators for other schemes", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newChartServer(t, "/mychart-1.2.3.tgz")
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.2.3",
+			RepositoryCache: t.TempDir(),
+			Locators: map[string]locator.LocatorFactory{
+				"s3": func(_ context.Context, _ *locator.SchemeRequest) (locator.Locator, error) {
+					t.Error("s3 locator called for an HTTP repository")
+
+					return nil, errors.New("unexpected")
+				},
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.SourceType).To(Equal(locator.SourceRepo))
+	})
+}
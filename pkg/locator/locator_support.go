@@ -5,6 +5,8 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -16,8 +18,12 @@ import (
 	"strings"
 	"time"
 
+	godigest "github.com/opencontainers/go-digest"
 	chart "helm.sh/helm/v4/pkg/chart/v2"
 	"sigs.k8s.io/yaml"
+
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/container"
+	"github.com/k8s-manifest-kit/renderer-helm/pkg/internal/fsutil"
 )
 
 const (
@@ -42,19 +48,105 @@ var (
 
 	// ErrChartYAMLTooLarge is returned when a Chart.yaml entry in a tar archive exceeds the size limit.
 	ErrChartYAMLTooLarge = errors.New("chart.yaml too large")
+
+	// ErrInvalidCAFile is returned when a CA file contains no usable PEM certificates.
+	ErrInvalidCAFile = errors.New("CA file contains no valid certificates")
 )
 
-func newHTTPClient() *http.Client {
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
 	return &http.Client{
 		Timeout: httpTimeout,
 		Transport: &http.Transport{
 			DialContext:           (&net.Dialer{Timeout: dialTimeout}).DialContext,
+			TLSClientConfig:       tlsConfig,
 			TLSHandshakeTimeout:   tlsHandshakeTimeout,
 			ResponseHeaderTimeout: responseTimeout,
 		},
 	}
 }
 
+// build turns the file-based TLS settings into a *tls.Config. A custom CA is
+// added to the system pool rather than replacing it.
+func (c *TLSConfig) build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // explicitly requested by the user
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file %q: %w", c.CAFile, err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCAFile, c.CAFile)
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// originCredentials adds credentials to requests for origin only. Redirects
+// to other origins, including other ports of the same host which http.Client
+// would still send Authorization to, go out without them.
+type originCredentials struct {
+	base   http.RoundTripper
+	origin *url.URL
+	creds  *Credentials
+}
+
+// withOriginCredentials returns a copy of client that authenticates requests
+// sharing the origin of u with creds.
+func withOriginCredentials(client *http.Client, u *url.URL, creds *Credentials) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	scoped := *client
+	scoped.Transport = &originCredentials{base: base, origin: u, creds: creds}
+
+	return &scoped
+}
+
+func (t *originCredentials) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Del("Authorization")
+
+	if urlsShareOrigin(t.origin, req.URL) {
+		switch {
+		case t.creds.BearerToken != "":
+			req.Header.Set("Authorization", "Bearer "+t.creds.BearerToken)
+		case t.creds.Username != "" || t.creds.Password != "":
+			req.SetBasicAuth(t.creds.Username, t.creds.Password)
+		}
+
+		for k, v := range t.creds.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+
+	return t.base.RoundTrip(req) //nolint:wrapcheck // transparent transport
+}
+
 func urlsShareOrigin(u1 *url.URL, u2 *url.URL) bool {
 	return u1.Scheme == u2.Scheme &&
 		u1.Hostname() == u2.Hostname() &&
@@ -76,28 +168,63 @@ func effectivePort(u *url.URL) string {
 	}
 }
 
+// httpResponse is the outcome of httpFetch. NotModified is set when the
+// server answered a conditional request with 304; Data is empty in that case.
+type httpResponse struct {
+	Data        []byte
+	Header      http.Header
+	NotModified bool
+}
+
 func httpGet(ctx context.Context, client *http.Client, rawURL string, creds *Credentials) ([]byte, error) {
+	resp, err := httpFetch(ctx, client, rawURL, creds, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
+
+func httpFetch(
+	ctx context.Context,
+	client *http.Client,
+	rawURL string,
+	creds *Credentials,
+	header http.Header,
+) (*httpResponse, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create HTTP request: %w", err)
 	}
 
-	if creds.hasAuth() {
-		req.SetBasicAuth(creds.Username, creds.Password)
+	for k, v := range header {
+		req.Header[k] = v
 	}
 
 	if client == nil {
-		client = newHTTPClient()
+		client = newHTTPClient(nil)
+	}
+
+	if creds.hasAuth() {
+		client = withOriginCredentials(client, req.URL, creds)
 	}
 
 	resp, err := client.Do(req) //nolint:gosec // URL is constructed from user-provided repo config
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return nil, container.WrapCertificateError(req.URL.Host, fmt.Errorf("HTTP request failed: %w", err))
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode == http.StatusNotModified && len(header) > 0 {
+		return &httpResponse{Header: resp.Header, NotModified: true}, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%w: %d from %s", ErrUnexpectedStatus, resp.StatusCode, rawURL)
+		return nil, &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			URL:        rawURL,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize+1))
@@ -109,7 +236,7 @@ func httpGet(ctx context.Context, client *http.Client, rawURL string, creds *Cre
 		return nil, fmt.Errorf("%w: %d bytes from %s", ErrResponseTooLarge, len(data), rawURL)
 	}
 
-	return data, nil
+	return &httpResponse{Data: data, Header: resp.Header}, nil
 }
 
 // ExtractChartMeta opens a .tgz chart archive and returns the metadata from
@@ -173,26 +300,96 @@ func ExtractChartMeta(path string) (chart.Metadata, error) {
 	return meta, nil
 }
 
-func cacheChart(cacheDir string, data []byte) (string, error) {
+// cacheChart stores data under its sha256 digest and returns the absolute
+// path of the cached archive along with the digest in "sha256:<hex>" form.
+func cacheChart(cacheDir string, data []byte) (string, string, error) {
 	if cacheDir == "" {
-		return "", ErrEmptyCacheDir
+		return "", "", ErrEmptyCacheDir
 	}
 
 	if err := os.MkdirAll(cacheDir, dirPermissions); err != nil {
-		return "", fmt.Errorf("unable to create cache directory: %w", err)
+		return "", "", fmt.Errorf("unable to create cache directory: %w", err)
 	}
 
-	hash := sha256.Sum256(data)
-	filename := filepath.Join(cacheDir, fmt.Sprintf("%x.tgz", hash))
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	filename := cachedChartPath(cacheDir, digest)
 
-	if err := os.WriteFile(filename, data, filePermissions); err != nil {
-		return "", fmt.Errorf("unable to write chart to cache: %w", err)
+	if err := fsutil.WriteFileAtomic(filename, data); err != nil {
+		return "", "", fmt.Errorf("unable to write chart to cache: %w", err)
 	}
 
 	abs, err := filepath.Abs(filename)
 	if err != nil {
-		return "", fmt.Errorf("unable to resolve absolute path for %q: %w", filename, err)
+		return "", "", fmt.Errorf("unable to resolve absolute path for %q: %w", filename, err)
+	}
+
+	return abs, digest, nil
+}
+
+// cachedChartPath returns the cache location of the archive with the given
+// "sha256:<hex>" digest.
+func cachedChartPath(cacheDir string, digest string) string {
+	return filepath.Join(cacheDir, strings.TrimPrefix(digest, "sha256:")+".tgz")
+}
+
+// rewriteURL applies the first mirror whose prefix matches rawURL.
+func rewriteURL(mirrors []Mirror, rawURL string) string {
+	for _, m := range mirrors {
+		if m.Prefix != "" && strings.HasPrefix(rawURL, m.Prefix) {
+			return m.Replacement + strings.TrimPrefix(rawURL, m.Prefix)
+		}
+	}
+
+	return rawURL
+}
+
+// mirroredOrigin reports whether mirrors move rawURL to another origin, which
+// must not receive the credentials configured for rawURL.
+func mirroredOrigin(mirrors []Mirror, rawURL string) bool {
+	u1, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+
+	u2, err := url.Parse(rewriteURL(mirrors, rawURL))
+	if err != nil {
+		return true
+	}
+
+	return !urlsShareOrigin(u1, u2)
+}
+
+// verifyDigest checks data against an expected "<algorithm>:<hex>" digest.
+func verifyDigest(expected string, data []byte) error {
+	want := godigest.Digest(expected)
+	if err := want.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", container.ErrInvalidDigest, err)
+	}
+
+	if actual := want.Algorithm().FromBytes(data); actual != want {
+		return &DigestMismatchError{Expected: want, Actual: actual}
+	}
+
+	return nil
+}
+
+// lookupCachedChart returns the absolute path of the cached archive with the
+// given digest, if present. Only sha256 digests can match, since archives are
+// stored under their sha256.
+func lookupCachedChart(cacheDir string, digest string) (string, bool) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", false
+	}
+
+	path := cachedChartPath(cacheDir, digest)
+	if !fileExists(path) {
+		return "", false
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
 	}
 
-	return abs, nil
+	return abs, true
 }
@@ -4,7 +4,10 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"net/http"
@@ -83,6 +86,135 @@ func TestOCILocator_NonSemverTags(t *testing.T) {
 	g.Expect(errors.Is(err, locator.ErrNoValidSemverTag)).To(BeTrue())
 }
 
+func TestLocate_TLS(t *testing.T) {
+	t.Parallel()
+
+	newTLSServer := func(t *testing.T, clientAuth tls.ClientAuthType) *httptest.Server {
+		t.Helper()
+
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == indexPath {
+				_, _ = w.Write([]byte(repoIndexYAML))
+
+				return
+			}
+
+			_, _ = w.Write([]byte(chartData))
+		}))
+		srv.TLS = &tls.Config{ClientAuth: clientAuth, MinVersion: tls.VersionTLS12}
+		srv.StartTLS()
+		t.Cleanup(srv.Close)
+
+		return srv
+	}
+
+	locate := func(t *testing.T, srv *httptest.Server, cfg *locator.TLSConfig) error {
+		t.Helper()
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			TLS:             cfg,
+		})
+
+		return err
+	}
+
+	t.Run("should report untrusted server certificates as CertificateError", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSServer(t, tls.NoClientCert)
+
+		err := locate(t, srv, nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsCertificateError(err)).To(BeTrue())
+
+		var certErr *locator.CertificateError
+		g.Expect(errors.As(err, &certErr)).To(BeTrue())
+		g.Expect(certErr.Host).To(Equal(srv.Listener.Addr().String()))
+	})
+
+	t.Run("should trust a custom CA", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSServer(t, tls.NoClientCert)
+
+		g.Expect(locate(t, srv, &locator.TLSConfig{CAFile: writeCAFile(t, srv)})).To(Succeed())
+	})
+
+	t.Run("should verify the certificate against ServerName", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSServer(t, tls.NoClientCert)
+		caFile := writeCAFile(t, srv)
+
+		// The httptest certificate is valid for example.com.
+		g.Expect(locate(t, srv, &locator.TLSConfig{CAFile: caFile, ServerName: "example.com"})).To(Succeed())
+
+		err := locate(t, srv, &locator.TLSConfig{CAFile: caFile, ServerName: "other.example.org"})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsCertificateError(err)).To(BeTrue())
+	})
+
+	t.Run("should present a client certificate", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSServer(t, tls.RequireAnyClientCert)
+		caFile := writeCAFile(t, srv)
+
+		err := locate(t, srv, &locator.TLSConfig{CAFile: caFile})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsCertificateError(err)).To(BeTrue())
+
+		certFile, keyFile := writeKeyPair(t, srv)
+		g.Expect(locate(t, srv, &locator.TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile})).
+			To(Succeed())
+	})
+
+	t.Run("should not report other network errors as CertificateError", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newTLSServer(t, tls.NoClientCert)
+		srv.Close()
+
+		err := locate(t, srv, &locator.TLSConfig{InsecureSkipVerify: true})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsCertificateError(err)).To(BeFalse())
+	})
+
+	t.Run("should apply TLS settings to OCI registries", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		plain := newMockOCIRegistry(t, []byte("tls-oci-chart"))
+		srv := httptest.NewTLSServer(plain.Config.Handler)
+		t.Cleanup(srv.Close)
+
+		oci := &locator.OCI{
+			Ref:      "oci://" + srv.Listener.Addr().String() + "/test/chart",
+			Version:  "1.0.0",
+			CacheDir: t.TempDir(),
+		}
+
+		_, err := oci.Locate(t.Context())
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsCertificateError(err)).To(BeTrue())
+
+		oci.TLS = &locator.TLSConfig{CAFile: writeCAFile(t, srv)}
+
+		result, err := oci.Locate(t.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+	})
+}
+
 // ---------------------------------------------------------------------------
 // Helpers
 // ---------------------------------------------------------------------------
@@ -171,3 +303,44 @@ func newMockOCIRegistryWithTagList(t *testing.T, tags []string) *tagOnlyOCIServe
 		ref:    host + "/" + repo,
 	}
 }
+
+// writeCAFile writes the certificate of a TLS test server as a PEM CA bundle.
+func writeCAFile(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// writeKeyPair writes the certificate and key of a TLS test server as PEM
+// files, for use as a client certificate.
+func writeKeyPair(t *testing.T, srv *httptest.Server) (string, string) {
+	t.Helper()
+
+	key, err := x509.MarshalPKCS8PrivateKey(srv.TLS.Certificates[0].PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
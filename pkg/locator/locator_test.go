@@ -9,6 +9,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/k8s-manifest-kit/renderer-helm/pkg/locator"
@@ -213,6 +216,96 @@ func TestLocate(t *testing.T) {
 	})
 }
 
+func TestLocate_HTTPClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should send repository requests through the transport", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newChartServer(t, "/mychart-1.2.3.tgz")
+
+		var (
+			mu    sync.Mutex
+			paths []string
+		)
+
+		transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			mu.Lock()
+			paths = append(paths, r.URL.Path)
+			mu.Unlock()
+
+			return http.DefaultTransport.RoundTrip(r)
+		})
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.2.3",
+			RepositoryCache: t.TempDir(),
+			Transport:       transport,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(paths).To(Equal([]string{indexPath, "/mychart-1.2.3.tgz"}))
+	})
+
+	t.Run("should send registry requests through the transport", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := newMockOCIRegistry(t, []byte("transport-oci-chart"))
+
+		var requests atomic.Int32
+
+		// The registry stand-in only speaks plain HTTP, which the transport
+		// takes care of.
+		transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			requests.Add(1)
+
+			r = r.Clone(r.Context())
+			r.URL.Scheme = "http"
+
+			return http.DefaultTransport.RoundTrip(r)
+		})
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "oci://" + srv.ref,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Transport:       transport,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+		g.Expect(requests.Load()).To(BeNumerically(">=", 2))
+	})
+
+	t.Run("should use the HTTP client instead of the TLS settings", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == indexPath {
+				_, _ = w.Write([]byte(repoIndexYAML))
+
+				return
+			}
+
+			_, _ = w.Write([]byte(chartData))
+		}))
+		t.Cleanup(srv.Close)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			TLS:             &locator.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			HTTPClient:      srv.Client(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
+
 func TestRepoLocator_Download(t *testing.T) {
 	t.Parallel()
 
@@ -304,6 +397,310 @@ func TestRepoLocator_Download(t *testing.T) {
 	})
 }
 
+func TestRepoLocator_CachedArchive(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should skip the download when the index digest is cached", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, archives := newDigestChartServer(t, fmt.Sprintf("%x", sha256.Sum256([]byte(chartData))))
+		cacheDir := t.TempDir()
+
+		var paths []string
+
+		for range 3 {
+			result, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         "1.0.0",
+				RepositoryCache: cacheDir,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			paths = append(paths, result.Path)
+		}
+
+		g.Expect(archives.Load()).To(Equal(int32(1)))
+		g.Expect(paths).To(HaveEach(paths[0]))
+	})
+
+	t.Run("should download every time when the index has no digest", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, archives := newDigestChartServer(t, `""`)
+		cacheDir := t.TempDir()
+
+		for range 2 {
+			_, err := locator.Locate(t.Context(), &locator.Request{
+				Name:            "mychart",
+				RepoURL:         srv.URL,
+				Version:         "1.0.0",
+				RepositoryCache: cacheDir,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+		}
+
+		g.Expect(archives.Load()).To(Equal(int32(2)))
+	})
+}
+
+func TestRepoLocator_DigestVerification(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should reject an archive that does not match the index digest", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDigestChartServer(t, fmt.Sprintf("%x", sha256.Sum256([]byte("other-content"))))
+		cacheDir := t.TempDir()
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: cacheDir,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(locator.IsDigestMismatchError(err)).To(BeTrue())
+
+		cached, err := filepath.Glob(filepath.Join(cacheDir, "*.tgz"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cached).To(BeEmpty())
+	})
+
+	t.Run("should accept a prefixed index digest", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newDigestChartServer(t, fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(chartData))))
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			RequireDigest:   true,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+	})
+
+	t.Run("should refuse entries without digest when required", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, archives := newDigestChartServer(t, `""`)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			RequireDigest:   true,
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrMissingDigest)).To(BeTrue())
+		g.Expect(archives.Load()).To(BeZero())
+	})
+}
+
+func TestRepoLocator_DownloadFallback(t *testing.T) {
+	t.Parallel()
+
+	const multiURLIndexTmpl = `apiVersion: v1
+entries:
+  mychart:
+    - version: "1.0.0"
+      urls:
+        - %s/broken/mychart-1.0.0.tgz
+        - %s/mychart-1.0.0.tgz
+`
+
+	newFallbackServer := func(t *testing.T, secondWorks bool) (*httptest.Server, *[]string) {
+		t.Helper()
+
+		var (
+			mu   sync.Mutex
+			hits []string
+		)
+
+		var srv *httptest.Server
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits = append(hits, r.URL.Path)
+			mu.Unlock()
+
+			switch {
+			case r.URL.Path == indexPath:
+				_, _ = fmt.Fprintf(w, multiURLIndexTmpl, srv.URL, srv.URL)
+			case strings.HasPrefix(r.URL.Path, "/broken/") || !secondWorks:
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			default:
+				_, _ = w.Write([]byte(chartData))
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		return srv, &hits
+	}
+
+	t.Run("should try every URL in index order", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, hits := newFallbackServer(t, true)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+		g.Expect(*hits).To(Equal([]string{indexPath, "/broken/mychart-1.0.0.tgz", "/mychart-1.0.0.tgz"}))
+	})
+
+	t.Run("should report every failed URL", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv, _ := newFallbackServer(t, false)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Is(err, locator.ErrUnexpectedStatus)).To(BeTrue())
+		g.Expect(err.Error()).To(And(
+			ContainSubstring("/broken/mychart-1.0.0.tgz"),
+			ContainSubstring(srv.URL+"/mychart-1.0.0.tgz"),
+		))
+	})
+}
+
+func TestRepoLocator_Mirrors(t *testing.T) {
+	t.Parallel()
+
+	const upstream = "https://charts.upstream.example"
+
+	t.Run("should rewrite index and archive URLs", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var paths []string
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+
+			switch r.URL.Path {
+			case "/mirror/index.yaml":
+				_, _ = fmt.Fprintf(w, crossOriginRepoIndexTmpl, upstream)
+			case "/mirror/mychart-1.0.0.tgz":
+				_, _ = w.Write([]byte(chartData))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		result, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         upstream,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Mirrors: []locator.Mirror{
+				{Prefix: "https://unrelated.example", Replacement: "http://127.0.0.1:1"},
+				{Prefix: upstream, Replacement: srv.URL + "/mirror"},
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.Path).To(BeARegularFile())
+		g.Expect(paths).To(Equal([]string{"/mirror/index.yaml", "/mirror/mychart-1.0.0.tgz"}))
+	})
+
+	t.Run("should not send the repository credentials to the mirror", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var mu sync.Mutex
+
+		received := map[string]string{}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[r.URL.Path] = r.Header.Get("Authorization")
+			mu.Unlock()
+
+			if r.URL.Path == indexPath {
+				_, _ = fmt.Fprintf(w, crossOriginRepoIndexTmpl, upstream)
+
+				return
+			}
+
+			_, _ = w.Write([]byte(chartData))
+		}))
+		t.Cleanup(srv.Close)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         upstream,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Mirrors:         []locator.Mirror{{Prefix: upstream, Replacement: srv.URL}},
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{Username: "user", Password: "pass"}, nil
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(received).To(Equal(map[string]string{
+			indexPath:            "",
+			"/mychart-1.0.0.tgz": "",
+		}))
+	})
+
+	t.Run("should keep offline lookups keyed by the original repo URL", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == indexPath {
+				_, _ = fmt.Fprintf(w, crossOriginRepoIndexTmpl, upstream)
+
+				return
+			}
+
+			_, _ = w.Write([]byte(chartData))
+		}))
+		t.Cleanup(srv.Close)
+
+		cacheDir := t.TempDir()
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         upstream,
+			Version:         "1.0.0",
+			RepositoryCache: cacheDir,
+			Mirrors:         []locator.Mirror{{Prefix: upstream, Replacement: srv.URL}},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         upstream,
+			Version:         "1.0.0",
+			RepositoryCache: cacheDir,
+			Offline:         true,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
+
 func TestRepoLocator_Credentials(t *testing.T) {
 	t.Parallel()
 
@@ -365,6 +762,114 @@ func TestRepoLocator_Credentials(t *testing.T) {
 		})
 		g.Expect(err).ToNot(HaveOccurred())
 	})
+
+	t.Run("should send bearer tokens and custom headers", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var mu sync.Mutex
+
+		received := map[string][]string{}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[r.URL.Path] = []string{r.Header.Get("Authorization"), r.Header.Get("X-JFrog-Art-Api")}
+			mu.Unlock()
+
+			if r.URL.Path == indexPath {
+				_, _ = w.Write([]byte(repoIndexYAML))
+
+				return
+			}
+
+			_, _ = w.Write([]byte(chartData))
+		}))
+		t.Cleanup(srv.Close)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         srv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{
+					BearerToken: "token",
+					Headers:     map[string]string{"X-JFrog-Art-Api": "api-key"},
+				}, nil
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(received).To(Equal(map[string][]string{
+			indexPath:            {"Bearer token", "api-key"},
+			"/mychart-1.0.0.tgz": {"Bearer token", "api-key"},
+		}))
+	})
+
+	t.Run("should not forward custom headers for cross-origin chart URL", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		chartSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			g.Expect(r.Header.Get("X-JFrog-Art-Api")).To(BeEmpty(), "headers should not leak cross-origin")
+			_, _ = w.Write([]byte(chartData))
+		}))
+		t.Cleanup(chartSrv.Close)
+
+		repoSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(crossOriginRepoIndex(chartSrv.URL)))
+		}))
+		t.Cleanup(repoSrv.Close)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         repoSrv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{Headers: map[string]string{"X-JFrog-Art-Api": "api-key"}}, nil
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("should not forward custom headers on cross-origin redirects", func(t *testing.T) {
+		t.Parallel()
+		g := NewWithT(t)
+
+		var leaked atomic.Bool
+
+		chartSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			leaked.Store(r.Header.Get("X-JFrog-Art-Api") != "" || r.Header.Get("Authorization") != "")
+			_, _ = w.Write([]byte(chartData))
+		}))
+		t.Cleanup(chartSrv.Close)
+
+		repoSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == indexPath {
+				_, _ = w.Write([]byte(repoIndexYAML))
+
+				return
+			}
+
+			http.Redirect(w, r, chartSrv.URL+r.URL.Path, http.StatusFound)
+		}))
+		t.Cleanup(repoSrv.Close)
+
+		_, err := locator.Locate(t.Context(), &locator.Request{
+			Name:            "mychart",
+			RepoURL:         repoSrv.URL,
+			Version:         "1.0.0",
+			RepositoryCache: t.TempDir(),
+			Credentials: func(_ context.Context) (*locator.Credentials, error) {
+				return &locator.Credentials{
+					BearerToken: "token",
+					Headers:     map[string]string{"X-JFrog-Art-Api": "api-key"},
+				}, nil
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(leaked.Load()).To(BeFalse())
+	})
 }
 
 func TestRepoLocator_EmptyRepoURL(t *testing.T) {
@@ -448,6 +953,13 @@ entries:
 	g.Expect(requestedPath).To(Equal("/etc/mychart-1.0.0.tgz"))
 }
 
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
 func crossOriginRepoIndex(chartBaseURL string) string {
 	return fmt.Sprintf(crossOriginRepoIndexTmpl, chartBaseURL)
 }
@@ -469,3 +981,34 @@ func newChartServer(t *testing.T, chartPath string) *httptest.Server {
 
 	return srv
 }
+
+// newDigestChartServer serves a single-entry index with the given digest and
+// counts archive downloads.
+func newDigestChartServer(t *testing.T, digest string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var archives atomic.Int32
+
+	index := fmt.Sprintf(`apiVersion: v1
+entries:
+  mychart:
+    - version: "1.0.0"
+      digest: %s
+      urls:
+        - mychart-1.0.0.tgz
+`, digest)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == indexPath {
+			_, _ = w.Write([]byte(index))
+
+			return
+		}
+
+		archives.Add(1)
+		_, _ = w.Write([]byte(chartData))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &archives
+}
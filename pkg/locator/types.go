@@ -3,7 +3,7 @@ package locator
 import (
 	"errors"
 	"fmt"
-	"sort"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 )
@@ -14,8 +14,9 @@ var (
 	ErrVersionNotFound = errors.New("version not found")
 )
 
-// Minimal structs for parsing a Helm repository index.yaml without importing
-// the repo/v1 package (which transitively pulls in controller-runtime).
+// Minimal structs for parsing a Helm repository index.yaml and the
+// repositories.yaml file without importing the repo/v1 package (which
+// transitively pulls in controller-runtime).
 //
 // These use json struct tags because sigs.k8s.io/yaml (used for unmarshaling)
 // converts YAML to JSON internally and then uses encoding/json, so only json
@@ -26,12 +27,40 @@ type repoIndex struct {
 }
 
 type repoChartVersion struct {
-	Version string   `json:"version"`
-	URLs    []string `json:"urls"`
+	Version    string   `json:"version"`
+	URLs       []string `json:"urls"`
+	Digest     string   `json:"digest"`
+	Deprecated bool     `json:"deprecated"`
 }
 
 type repoChartVersions []repoChartVersion
 
+type repoFile struct {
+	Repositories []*repoEntry `json:"repositories"`
+}
+
+type repoEntry struct {
+	Name                  string `json:"name"`
+	URL                   string `json:"url"`
+	Username              string `json:"username"`
+	Password              string `json:"password"` //nolint:gosec // not a hardcoded credential, just a field name
+	CertFile              string `json:"certFile"`
+	KeyFile               string `json:"keyFile"`
+	CAFile                string `json:"caFile"`
+	InsecureSkipTLSVerify bool   `json:"insecure_skip_tls_verify"`
+	PassCredentialsAll    bool   `json:"pass_credentials_all"`
+}
+
+// archiveDigest returns the index digest in "sha256:<hex>" form. Helm writes
+// a bare hex sha256 of the archive; an already prefixed value is kept as is.
+func (cv *repoChartVersion) archiveDigest() string {
+	if cv.Digest == "" || strings.Contains(cv.Digest, ":") {
+		return cv.Digest
+	}
+
+	return "sha256:" + strings.ToLower(cv.Digest)
+}
+
 func (vs repoChartVersions) Len() int      { return len(vs) }
 func (vs repoChartVersions) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
 
@@ -46,39 +75,66 @@ func (vs repoChartVersions) Less(i, j int) bool {
 	return vi.GreaterThan(vj)
 }
 
-func (idx *repoIndex) resolve(name string, version string) (*repoChartVersion, error) {
+// resolve picks the chart version matching the given version or constraint
+// among the versions eligible under policy, and reports why it was chosen.
+// A version equal to an entry is used as is, subject only to the policy's
+// minimum version. Entries must already be sorted newest first (see
+// parseRepoIndex).
+func (idx *repoIndex) resolve(
+	name string,
+	version string,
+	policy versionPolicy,
+) (*repoChartVersion, ResolutionReason, error) {
 	versions, ok := idx.Entries[name]
 	if !ok || len(versions) == 0 {
-		return nil, fmt.Errorf("%w: %q", ErrChartNotFound, name)
+		return nil, "", fmt.Errorf("%w: %q", ErrChartNotFound, name)
 	}
 
-	sort.Sort(versions)
-
 	if version == "" {
-		return &versions[0], nil
+		for i := range versions {
+			if policy.eligible(&versions[i], parseVersion(versions[i].Version)) {
+				return &versions[i], ReasonLatest, nil
+			}
+		}
+
+		return nil, "", fmt.Errorf("%w: no eligible version of chart %q", ErrVersionNotFound, name)
 	}
 
-	constraint, err := semver.NewConstraint(version)
-	if err != nil {
-		for i := range versions {
-			if versions[i].Version == version {
-				return &versions[i], nil
+	for i := range versions {
+		if versions[i].Version == version {
+			if err := policy.checkExact(version); err != nil {
+				return nil, "", err
 			}
+
+			return &versions[i], ReasonExact, nil
 		}
+	}
 
-		return nil, fmt.Errorf("%w: %q for chart %q", ErrVersionNotFound, version, name)
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %q for chart %q", ErrVersionNotFound, version, name)
 	}
 
+	constraint.IncludePrerelease = policy.prereleases
+
 	for i := range versions {
-		v, err := semver.NewVersion(versions[i].Version)
-		if err != nil {
+		v := parseVersion(versions[i].Version)
+		if v == nil || !constraint.Check(v) || !policy.matchable(&versions[i], v) {
 			continue
 		}
 
-		if constraint.Check(v) {
-			return &versions[i], nil
-		}
+		return &versions[i], ReasonConstraint, nil
+	}
+
+	return nil, "", fmt.Errorf("%w: no match for %q in chart %q", ErrVersionNotFound, version, name)
+}
+
+// parseVersion returns the semver form of version, or nil if it is not semver.
+func parseVersion(version string) *semver.Version {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil
 	}
 
-	return nil, fmt.Errorf("%w: no match for %q in chart %q", ErrVersionNotFound, version, name)
+	return v
 }